@@ -8,6 +8,7 @@ import (
     "encoding/base64"
     "errors"
     "hash"
+    "sync"
 )
 
 const (
@@ -19,10 +20,14 @@ const (
     SHA512 = crypto.Hash(crypto.SHA512)
 )
 
-var pool = make([]*HMAC, 0, 1)
+var (
+    pool      = make([]*HMAC, 0, 1)
+    poolMutex sync.Mutex
+)
 
-// HMAC
+// HMAC is shared by the callers with the same hash and secret
 type HMAC struct {
+    sync.Mutex
     secret string
     crypto crypto.Hash
     hash   hash.Hash
@@ -31,6 +36,9 @@ type HMAC struct {
 
 // NewHMAC creates a new HMAC signing method
 func NewHMAC(c crypto.Hash, secret string) *HMAC {
+    poolMutex.Lock()
+    defer poolMutex.Unlock()
+
     var hm *HMAC
     if len(pool) > 0 {
         for _, hm = range pool {
@@ -69,6 +77,10 @@ func (h *HMAC) String() string {
 
 // Sign signs a hp and returns the signature
 func (h *HMAC) Sign(hp []byte) ([]byte, error) {
+    h.Lock()
+    defer h.Unlock()
+
+    h.hash.Reset()
     if _, err := h.hash.Write(hp); err != nil {
         return nil, err
     }
@@ -47,10 +47,10 @@ func (log *Log) Write(v ...interface{}) (n int, err error) {
     return
 }
 
-// PrefixWrite
+// PrefixWrite, the request id and trace id are written first if the first value is the request context
 func (log *Log) PrefixWrite(prefix string, v ...interface{}) (n int, err error) {
     if log.writer != nil {
-        n, err = fmt.Fprintf(log.writer, "%s %s %s", prefix, time.Now().Format("2006/01/02 15:04:05.000"), fmt.Sprintln(v...))
+        n, err = fmt.Fprintf(log.writer, "%s %s %s", prefix, time.Now().Format("2006/01/02 15:04:05.000"), fmt.Sprintln(withTrace(v)...))
     }
 
     return
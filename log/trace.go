@@ -0,0 +1,55 @@
+package log
+
+import (
+    "context"
+)
+
+// Trace identifies a request and its W3C trace context, see https://www.w3.org/TR/trace-context/
+type Trace struct {
+    RequestId string
+    TraceId   string // 32 lowercase hex digits
+    ParentId  string // span id of the caller, empty if the trace is started here
+    SpanId    string // 16 lowercase hex digits
+    Flags     string // 2 lowercase hex digits, e.g. 01 for sampled
+    State     string // the tracestate header passed through
+}
+
+// traceKey is the context key of the trace
+type traceKey struct{}
+
+// WithTrace returns a copy of the context carrying the trace
+func WithTrace(ctx context.Context, trace *Trace) context.Context {
+    return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFrom returns the trace of the context, nil if absent
+func TraceFrom(ctx context.Context) *Trace {
+    trace, _ := ctx.Value(traceKey{}).(*Trace)
+
+    return trace
+}
+
+// Traceparent returns the traceparent header of the outgoing requests
+func (trace *Trace) Traceparent() string {
+    return "00-" + trace.TraceId + "-" + trace.SpanId + "-" + trace.Flags
+}
+
+// String
+func (trace *Trace) String() string {
+    return "request_id=" + trace.RequestId + " trace_id=" + trace.TraceId
+}
+
+// withTrace replaces the leading context of the values with its trace
+func withTrace(v []interface{}) []interface{} {
+    if len(v) > 0 {
+        if ctx, ok := v[0].(context.Context); ok {
+            if trace := TraceFrom(ctx); trace != nil {
+                return append([]interface{}{trace.String()}, v[1:]...)
+            }
+
+            return v[1:]
+        }
+    }
+
+    return v
+}
@@ -0,0 +1,107 @@
+package router
+
+import (
+    "errors"
+    "github.com/lanseyujie/journey/jwt"
+    "net/http"
+    "strings"
+)
+
+var (
+    ErrTokenMissing = errors.New("router: bearer token missing")
+    ErrTokenClaims  = errors.New("router: bearer token claims invalid")
+)
+
+// JwtAuth authenticates the requests by the bearer token of the Authorization header,
+// or the cookie or the query parameter if their names are set
+type JwtAuth struct {
+    secret     string
+    Realm      string
+    Issuer     string   // checked if not empty
+    Audience   []string // checked if not empty
+    CookieName string
+    QueryName  string
+    // Validate checks the verified token further, e.g. whether its id is revoked
+    Validate func(httpCtx *Context, token *jwt.Jwt) error
+}
+
+// NewJwtAuth
+func NewJwtAuth(secret string) *JwtAuth {
+    return &JwtAuth{
+        secret: secret,
+        Realm:  "journey",
+    }
+}
+
+// Jwt returns the verified token of the request, nil if the jwt middleware is not used
+func (ctx *Context) Jwt() *jwt.Jwt {
+    return ctx.token
+}
+
+// Middleware verifies the token and its claims, and sets the subject by AuthUserKey,
+// the request is rejected with 401
+// and the WWW-Authenticate header through the error handler if the token is missing or invalid
+func (a *JwtAuth) Middleware() HandlerFunc {
+    return func(httpCtx *Context) {
+        token, err := a.Authenticate(httpCtx)
+        if err != nil {
+            challenge := `Bearer realm="` + a.Realm + `"`
+            if err != ErrTokenMissing {
+                challenge += `, error="invalid_token", error_description="` + strings.ReplaceAll(err.Error(), `"`, `'`) + `"`
+            }
+            httpCtx.SetHeader("WWW-Authenticate", challenge)
+            httpCtx.Error(http.StatusUnauthorized)
+
+            return
+        }
+
+        httpCtx.token = token
+        httpCtx.Set(AuthUserKey, token.Payload.Subject)
+        httpCtx.Next()
+    }
+}
+
+// Authenticate returns the verified token of the request
+func (a *JwtAuth) Authenticate(httpCtx *Context) (*jwt.Jwt, error) {
+    raw := a.extract(httpCtx)
+    if raw == "" {
+        return nil, ErrTokenMissing
+    }
+
+    token := jwt.NewJwt()
+    if err := token.Verify(raw, a.secret); err != nil {
+        return nil, err
+    }
+
+    if !token.CheckExpiration() || !token.CheckNotBefore() || !token.CheckIssuedAt() ||
+        (a.Issuer != "" && !token.CheckIssuer(a.Issuer)) || (len(a.Audience) > 0 && !token.CheckAudience(a.Audience)) {
+        return nil, ErrTokenClaims
+    }
+
+    if a.Validate != nil {
+        if err := a.Validate(httpCtx, token); err != nil {
+            return nil, err
+        }
+    }
+
+    return token, nil
+}
+
+// extract the raw token from the Authorization header, the cookie or the query parameter
+func (a *JwtAuth) extract(httpCtx *Context) string {
+    if auth := httpCtx.GetAuth(); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+        return strings.TrimSpace(auth[7:])
+    }
+
+    if a.CookieName != "" {
+        if cookie, err := httpCtx.GetCookie(a.CookieName); err == nil && cookie.Value != "" {
+            return cookie.Value
+        }
+    }
+
+    if a.QueryName != "" {
+        return httpCtx.GetQuery(a.QueryName)
+    }
+
+    return ""
+}
@@ -0,0 +1,75 @@
+package router
+
+import (
+    "github.com/lanseyujie/journey/jwt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestJwtAuth(t *testing.T) {
+    sign := func(payload jwt.Payload) string {
+        token := jwt.NewJwt()
+        *token.Payload = payload
+        signed, err := token.Sign(jwt.NewHMAC(jwt.SHA256, "secret"))
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        return string(signed)
+    }
+    valid := sign(jwt.Payload{Issuer: "journey", Subject: "jike", Expiration: time.Now().Add(time.Hour).Unix()})
+    expired := sign(jwt.Payload{Issuer: "journey", Subject: "jike", Expiration: time.Now().Add(-time.Hour).Unix()})
+    issuer := sign(jwt.Payload{Issuer: "other", Subject: "jike"})
+
+    auth := NewJwtAuth("secret")
+    auth.Issuer = "journey"
+    auth.CookieName = "token"
+    auth.QueryName = "token"
+    router := NewRouter()
+    router.Group("/").Use(auth.Middleware())
+    router.Get("/user", func(httpCtx *Context) {
+        httpCtx.Text(http.StatusOK, []byte(httpCtx.Jwt().Payload.Subject))
+    })
+
+    tests := []struct {
+        name      string
+        header    string
+        cookie    string
+        query     string
+        code      int
+        challenge string
+    }{
+        {name: "1", header: "Bearer " + valid, code: http.StatusOK},
+        {name: "2", cookie: valid, code: http.StatusOK},
+        {name: "3", query: valid, code: http.StatusOK},
+        {name: "4", code: http.StatusUnauthorized, challenge: `Bearer realm="journey"`},
+        {name: "5", header: "Basic " + valid, code: http.StatusUnauthorized, challenge: `Bearer realm="journey"`},
+        {name: "6", header: "Bearer " + expired, code: http.StatusUnauthorized,
+            challenge: `Bearer realm="journey", error="invalid_token", error_description="router: bearer token claims invalid"`},
+        {name: "7", header: "Bearer " + issuer, code: http.StatusUnauthorized,
+            challenge: `Bearer realm="journey", error="invalid_token", error_description="router: bearer token claims invalid"`},
+        {name: "8", header: "Bearer " + valid + "x", code: http.StatusUnauthorized,
+            challenge: `Bearer realm="journey", error="invalid_token", error_description="jwt: invalid signature"`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, "/user?token="+tt.query, nil)
+            if tt.header != "" {
+                r.Header.Set("Authorization", tt.header)
+            }
+            if tt.cookie != "" {
+                r.AddCookie(&http.Cookie{Name: "token", Value: tt.cookie})
+            }
+            router.ServeHTTP(w, r)
+            if w.Code != tt.code || w.Header().Get("WWW-Authenticate") != tt.challenge {
+                t.Errorf("ServeHTTP() = %v %v, want %v %v", w.Code, w.Header().Get("WWW-Authenticate"), tt.code, tt.challenge)
+            }
+            if tt.code == http.StatusOK && w.Body.String() != "jike" {
+                t.Errorf("Jwt() = %v, want jike", w.Body.String())
+            }
+        })
+    }
+}
@@ -0,0 +1,385 @@
+package router
+
+import (
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "github.com/lanseyujie/journey/utils"
+    "io"
+    "mime"
+    "net/url"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// DefaultMaxMemory is the max memory used to parse a multipart form, the rest is stored on disk
+const DefaultMaxMemory = 32 << 20
+
+var (
+    ErrBindTarget = errors.New("router: bind target must be a non-nil pointer to struct")
+    timeType      = reflect.TypeOf(time.Time{})
+)
+
+// FieldError describes a field that failed to bind or validate
+type FieldError struct {
+    Field   string `json:"field"`
+    Rule    string `json:"rule"`
+    Message string `json:"msg"`
+}
+
+// FieldErrors is returned by Bind, it can be rendered directly as the data of CanonicalJson
+type FieldErrors []FieldError
+
+// Error
+func (fe FieldErrors) Error() string {
+    msgs := make([]string, len(fe))
+    for i, e := range fe {
+        msgs[i] = e.Field + " " + e.Message
+    }
+
+    return "router: " + strings.Join(msgs, ", ")
+}
+
+// Bind fills the struct pointed by v from the request and validates it.
+// The body is decoded by the json or xml tags according to the Content-Type,
+// then the fields tagged with `param:"id"`, `form:"title"` or `query:"page"` are filled
+// from the path params, the post form and the query in turn,
+// `time:"2006-01-02"` sets the layout of time.Time fields, RFC 3339 by default,
+// `valid:"required,email,min=1,max=32"` declares the validation rules, see validateField.
+// A FieldErrors is returned if any field fails to convert or validate
+func (ctx *Context) Bind(v interface{}) error {
+    rv := reflect.ValueOf(v)
+    if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+        return ErrBindTarget
+    }
+
+    mediaType, _, _ := mime.ParseMediaType(ctx.Input.Header.Get("Content-Type"))
+    switch {
+    case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+        if err := json.NewDecoder(ctx.Input.Body).Decode(v); err != nil && err != io.EOF {
+            return err
+        }
+    case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+        if err := xml.NewDecoder(ctx.Input.Body).Decode(v); err != nil && err != io.EOF {
+            return err
+        }
+    case mediaType == "multipart/form-data":
+        if err := ctx.Input.ParseMultipartForm(DefaultMaxMemory); err != nil {
+            return err
+        }
+    case mediaType == "application/x-www-form-urlencoded":
+        if err := ctx.Input.ParseForm(); err != nil {
+            return err
+        }
+    }
+
+    var errs FieldErrors
+    ctx.bindStruct(rv.Elem(), ctx.Input.URL.Query(), &errs)
+    validateStruct(rv.Elem(), "", &errs)
+    if len(errs) > 0 {
+        return errs
+    }
+
+    return nil
+}
+
+// bindStruct fills the tagged fields of the struct, embedded structs are filled as well
+func (ctx *Context) bindStruct(rv reflect.Value, query url.Values, errs *FieldErrors) {
+    rt := rv.Type()
+    for i := 0; i < rt.NumField(); i++ {
+        sf := rt.Field(i)
+        fv := rv.Field(i)
+        if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+            ctx.bindStruct(fv, query, errs)
+            continue
+        }
+        // unexported field
+        if sf.PkgPath != "" {
+            continue
+        }
+
+        var values []string
+        if key := tagName(sf, "param"); key != "" {
+            if value, exist := ctx.GetParams(key); exist {
+                values = []string{value}
+            }
+        }
+        if key := tagName(sf, "form"); key != "" && values == nil {
+            values = ctx.Input.PostForm[key]
+        }
+        if key := tagName(sf, "query"); key != "" && values == nil {
+            values = query[key]
+        }
+        if len(values) == 0 {
+            continue
+        }
+
+        if err := setValue(fv, values, sf.Tag.Get("time")); err != nil {
+            *errs = append(*errs, FieldError{Field: fieldName(sf), Rule: "type", Message: err.Error()})
+        }
+    }
+}
+
+// setValue converts the values to the type of the field
+func setValue(fv reflect.Value, values []string, layout string) error {
+    if fv.Kind() == reflect.Ptr {
+        if fv.IsNil() {
+            fv.Set(reflect.New(fv.Type().Elem()))
+        }
+        fv = fv.Elem()
+    }
+
+    if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+        slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+        for i, value := range values {
+            if err := setScalar(slice.Index(i), value, layout); err != nil {
+                return err
+            }
+        }
+        fv.Set(slice)
+
+        return nil
+    }
+
+    return setScalar(fv, values[0], layout)
+}
+
+// setScalar converts the value to the type of the field
+func setScalar(fv reflect.Value, value, layout string) error {
+    if fv.Kind() == reflect.Ptr {
+        if fv.IsNil() {
+            fv.Set(reflect.New(fv.Type().Elem()))
+        }
+        fv = fv.Elem()
+    }
+
+    if fv.Kind() == reflect.String {
+        fv.SetString(value)
+
+        return nil
+    }
+
+    // leave the zero value for empty input
+    if value == "" {
+        return nil
+    }
+
+    if fv.Type() == timeType {
+        if layout == "" {
+            layout = time.RFC3339
+        }
+        t, err := time.Parse(layout, value)
+        if err != nil {
+            return errors.New("must be a time in the format " + layout)
+        }
+        fv.Set(reflect.ValueOf(t))
+
+        return nil
+    }
+
+    switch fv.Kind() {
+    case reflect.Bool:
+        b, err := strconv.ParseBool(value)
+        if err != nil {
+            return errors.New("must be a boolean")
+        }
+        fv.SetBool(b)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+        if err != nil {
+            return errors.New("must be an integer")
+        }
+        fv.SetInt(n)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+        if err != nil {
+            return errors.New("must be an unsigned integer")
+        }
+        fv.SetUint(n)
+    case reflect.Float32, reflect.Float64:
+        n, err := strconv.ParseFloat(value, fv.Type().Bits())
+        if err != nil {
+            return errors.New("must be a number")
+        }
+        fv.SetFloat(n)
+    case reflect.Slice:
+        // []byte
+        fv.SetBytes([]byte(value))
+    default:
+        return errors.New("unsupported type " + fv.Type().String())
+    }
+
+    return nil
+}
+
+// validateStruct checks the fields with valid tag, nested structs are checked as well
+func validateStruct(rv reflect.Value, prefix string, errs *FieldErrors) {
+    rt := rv.Type()
+    for i := 0; i < rt.NumField(); i++ {
+        sf := rt.Field(i)
+        fv := rv.Field(i)
+        if sf.PkgPath != "" && !sf.Anonymous {
+            continue
+        }
+
+        if rules := sf.Tag.Get("valid"); rules != "" {
+            validateField(fv, prefix+fieldName(sf), rules, errs)
+        }
+
+        for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+            fv = fv.Elem()
+        }
+        if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+            if sf.Anonymous {
+                validateStruct(fv, prefix, errs)
+            } else {
+                validateStruct(fv, prefix+fieldName(sf)+".", errs)
+            }
+        }
+    }
+}
+
+// validateField checks the field with the rules separated by commas:
+// required, email, url, phone, name, number, hash, min=n, max=n, len=n,
+// min, max and len are the length of strings, slices and maps or the value of numbers,
+// the other rules are skipped if the field is zero value and not required
+func validateField(fv reflect.Value, field, rules string, errs *FieldErrors) {
+    for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+        fv = fv.Elem()
+    }
+
+    for _, rule := range strings.Split(rules, ",") {
+        rule = strings.TrimSpace(rule)
+        name, arg := rule, ""
+        if i := strings.Index(rule, "="); i > 0 {
+            name, arg = rule[:i], rule[i+1:]
+        }
+
+        var message string
+        switch name {
+        case "required":
+            if isZero(fv) {
+                message = "is required"
+            }
+        case "email", "url", "phone", "name", "number", "hash":
+            if !isZero(fv) && !matchPattern(fv, name) {
+                message = "must be a valid " + name
+            }
+        case "min", "max", "len":
+            limit, err := strconv.ParseFloat(arg, 64)
+            if err != nil {
+                panic("router: invalid validation rule `" + rule + "` of " + field)
+            }
+            if isZero(fv) {
+                continue
+            }
+
+            size, isNumber := measure(fv)
+            if name == "min" && size < limit {
+                message = "must be at least " + arg
+            } else if name == "max" && size > limit {
+                message = "must be at most " + arg
+            } else if name == "len" && size != limit {
+                message = "must be exactly " + arg
+            }
+            if message != "" && !isNumber {
+                message += " in length"
+            }
+        default:
+            panic("router: unknown validation rule `" + rule + "` of " + field)
+        }
+
+        if message != "" {
+            *errs = append(*errs, FieldError{Field: field, Rule: name, Message: message})
+        }
+    }
+}
+
+// matchPattern checks the string or the strings of the field with the patterns in utils
+func matchPattern(fv reflect.Value, name string) bool {
+    var check func(string) bool
+    switch name {
+    case "email":
+        check = utils.IsEmail
+    case "url":
+        check = utils.IsUrl
+    case "phone":
+        check = utils.IsPhone
+    case "name":
+        check = utils.IsName
+    case "number":
+        check = utils.IsNumber
+    case "hash":
+        check = utils.IsHash
+    }
+
+    if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+        for i := 0; i < fv.Len(); i++ {
+            if !check(fmt.Sprint(fv.Index(i).Interface())) {
+                return false
+            }
+        }
+
+        return true
+    }
+
+    return check(fmt.Sprint(fv.Interface()))
+}
+
+// measure returns the length of strings, slices and maps or the value of numbers
+func measure(fv reflect.Value) (size float64, isNumber bool) {
+    switch fv.Kind() {
+    case reflect.String:
+        return float64(utf8.RuneCountInString(fv.String())), false
+    case reflect.Slice, reflect.Array, reflect.Map:
+        return float64(fv.Len()), false
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return float64(fv.Int()), true
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return float64(fv.Uint()), true
+    case reflect.Float32, reflect.Float64:
+        return fv.Float(), true
+    }
+
+    return 0, false
+}
+
+// isZero reports whether the field is nil, empty or zero value
+func isZero(fv reflect.Value) bool {
+    switch fv.Kind() {
+    case reflect.Slice, reflect.Map:
+        return fv.Len() == 0
+    case reflect.Invalid:
+        return true
+    }
+
+    return fv.IsZero()
+}
+
+// tagName returns the name in the tag, e.g. `json:"name,omitempty"` => name
+func tagName(sf reflect.StructField, tag string) string {
+    name := sf.Tag.Get(tag)
+    if i := strings.Index(name, ","); i >= 0 {
+        name = name[:i]
+    }
+    if name == "-" {
+        return ""
+    }
+
+    return name
+}
+
+// fieldName returns the name of the field used in the field errors
+func fieldName(sf reflect.StructField) string {
+    for _, tag := range []string{"param", "form", "query", "json", "xml"} {
+        if name := tagName(sf, tag); name != "" {
+            return name
+        }
+    }
+
+    return sf.Name
+}
@@ -0,0 +1,94 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+type bindPost struct {
+    Id      int       `param:"id"`
+    Title   string    `form:"title" json:"title" valid:"required,min=2,max=8"`
+    Email   string    `form:"email" json:"email" valid:"email"`
+    Tags    []string  `query:"tag" valid:"max=2"`
+    Draft   bool      `query:"draft"`
+    Created time.Time `query:"created" time:"2006-01-02"`
+}
+
+func TestContextBind(t *testing.T) {
+    tests := []struct {
+        name        string
+        contentType string
+        body        string
+        query       string
+        want        bindPost
+        wantErr     []string
+    }{
+        {
+            name:        "form",
+            contentType: "application/x-www-form-urlencoded",
+            body:        "title=hello&email=jike@example.com",
+            query:       "tag=a&tag=b&draft=true&created=2020-05-01",
+            want: bindPost{Id: 7, Title: "hello", Email: "jike@example.com", Tags: []string{"a", "b"}, Draft: true,
+                Created: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)},
+        },
+        {
+            name:        "json",
+            contentType: "application/json; charset=utf-8",
+            body:        `{"title":"hello"}`,
+            want:        bindPost{Id: 7, Title: "hello"},
+        },
+        {
+            name:        "invalid",
+            contentType: "application/json",
+            body:        `{"title":"a","email":"jike"}`,
+            query:       "tag=a&tag=b&tag=c&draft=maybe",
+            wantErr:     []string{"draft:type", "title:min", "email:email", "tag:max"},
+        },
+        {
+            name:    "required",
+            wantErr: []string{"title:required"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := httptest.NewRequest(http.MethodPost, "/post/7?"+tt.query, strings.NewReader(tt.body))
+            if tt.contentType != "" {
+                r.Header.Set("Content-Type", tt.contentType)
+            }
+            ctx := NewContext()
+            ctx.Input = r
+            ctx.addParam("id", "7")
+
+            var got bindPost
+            err := ctx.Bind(&got)
+            if tt.wantErr == nil {
+                if err != nil {
+                    t.Fatalf("Bind() error = %v", err)
+                }
+                if got.Id != tt.want.Id || got.Title != tt.want.Title || got.Email != tt.want.Email ||
+                    strings.Join(got.Tags, ",") != strings.Join(tt.want.Tags, ",") || got.Draft != tt.want.Draft ||
+                    !got.Created.Equal(tt.want.Created) {
+                    t.Fatalf("Bind() got = %+v, want %+v", got, tt.want)
+                }
+
+                return
+            }
+
+            errs, ok := err.(FieldErrors)
+            if !ok {
+                t.Fatalf("Bind() error = %v, want FieldErrors", err)
+            }
+            var rules []string
+            for _, e := range errs {
+                rules = append(rules, e.Field+":"+e.Rule)
+            }
+            if strings.Join(rules, " ") != strings.Join(tt.wantErr, " ") {
+                t.Fatalf("Bind() errors = %v, want %v", rules, tt.wantErr)
+            }
+        })
+    }
+}
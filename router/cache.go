@@ -0,0 +1,106 @@
+package router
+
+import (
+    "container/list"
+    "sync"
+)
+
+// matchCache is a concurrency-safe LRU cache of route matching results
+type matchCache struct {
+    sync.Mutex
+    size  int
+    items map[string]*list.Element
+    order *list.List
+}
+
+// matchResult is the resolved handlers and params of a request
+type matchResult struct {
+    key      string
+    version  uint64
+    handlers HandlersChain
+    params   []Param
+}
+
+// newMatchCache returns a match cache holding at most size results
+func newMatchCache(size int) *matchCache {
+    return &matchCache{
+        size:  size,
+        items: make(map[string]*list.Element),
+        order: list.New(),
+    }
+}
+
+// resize the cache, a size less than 1 disables the cache
+func (c *matchCache) resize(size int) {
+    c.Lock()
+    c.size = size
+    for c.order.Len() > 0 && c.order.Len() > size {
+        c.evict()
+    }
+    c.Unlock()
+}
+
+// load the cached result into the context,
+// results matched before the tree was last modified are ignored
+func (c *matchCache) load(ctx *Context, key string, version uint64) bool {
+    c.Lock()
+    defer c.Unlock()
+
+    element, exist := c.items[key]
+    if !exist {
+        return false
+    }
+
+    result := element.Value.(*matchResult)
+    if result.version != version {
+        c.order.Remove(element)
+        delete(c.items, key)
+
+        return false
+    }
+
+    c.order.MoveToFront(element)
+    ctx.handlers = result.handlers
+    *ctx.params = append((*ctx.params)[:0], result.params...)
+
+    return true
+}
+
+// store the handlers and params matched in the context
+func (c *matchCache) store(ctx *Context, key string, version uint64) {
+    c.Lock()
+    defer c.Unlock()
+
+    if c.size < 1 {
+        return
+    }
+
+    params := make([]Param, len(*ctx.params))
+    copy(params, *ctx.params)
+    result := &matchResult{
+        key:      key,
+        version:  version,
+        handlers: ctx.handlers,
+        params:   params,
+    }
+
+    if element, exist := c.items[key]; exist {
+        element.Value = result
+        c.order.MoveToFront(element)
+
+        return
+    }
+
+    for c.order.Len() >= c.size {
+        c.evict()
+    }
+    c.items[key] = c.order.PushFront(result)
+}
+
+// evict the least recently used result
+func (c *matchCache) evict() {
+    if element := c.order.Back(); element != nil {
+        c.order.Remove(element)
+        delete(c.items, element.Value.(*matchResult).key)
+    }
+}
@@ -0,0 +1,53 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestRouterCache(t *testing.T) {
+    router := NewRouter()
+    router.Get("/post/:id", func(httpCtx *Context) {
+        id, _ := httpCtx.GetParams("id")
+        httpCtx.Text(http.StatusOK, []byte("post "+id))
+    })
+
+    serve := func(path string) (int, string) {
+        w := httptest.NewRecorder()
+        r := httptest.NewRequest(http.MethodGet, path, nil)
+        router.ServeHTTP(w, r)
+
+        return w.Code, w.Body.String()
+    }
+
+    for i := 0; i < 3; i++ {
+        if code, body := serve("/post/1"); code != http.StatusOK || body != "post 1" {
+            t.Fatalf("ServeHTTP() = %d %s, want %d %s", code, body, http.StatusOK, "post 1")
+        }
+        if code, body := serve("/post/2"); code != http.StatusOK || body != "post 2" {
+            t.Fatalf("ServeHTTP() = %d %s, want %d %s", code, body, http.StatusOK, "post 2")
+        }
+    }
+    if n := router.cache.order.Len(); n != 2 {
+        t.Fatalf("cache length = %d, want %d", n, 2)
+    }
+
+    // a route registered after startup invalidates the cached result
+    router.Get("/post/1", func(httpCtx *Context) {
+        httpCtx.Text(http.StatusOK, []byte("first post"))
+    })
+    if code, body := serve("/post/1"); code != http.StatusOK || body != "first post" {
+        t.Fatalf("ServeHTTP() = %d %s, want %d %s", code, body, http.StatusOK, "first post")
+    }
+
+    // not found results are never cached
+    if code, _ := serve("/none"); code != http.StatusNotFound {
+        t.Fatalf("ServeHTTP() = %d, want %d", code, http.StatusNotFound)
+    }
+
+    router.SetCacheSize(1)
+    if n := router.cache.order.Len(); n != 1 {
+        t.Fatalf("cache length = %d, want %d", n, 1)
+    }
+}
@@ -0,0 +1,268 @@
+package router
+
+import (
+    "compress/flate"
+    "compress/gzip"
+    "io"
+    "mime"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// CompressTypes are the compressible content types by default, type/* matches all subtypes
+var CompressTypes = []string{
+    "text/*",
+    "application/json",
+    "application/javascript",
+    "application/xml",
+    "application/rss+xml",
+    "application/atom+xml",
+    "application/wasm",
+    "image/svg+xml",
+    "font/ttf",
+    "font/otf",
+}
+
+// MiddlewareCompress compresses the responses with gzip or deflate negotiated from Accept-Encoding,
+// responses smaller than minSize and the content types not in types (CompressTypes if empty) are sent as is,
+// so are HEAD, Range and event stream responses, it panics if the level is not a valid gzip level
+func MiddlewareCompress(level, minSize int, types ...string) HandlerFunc {
+    if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+        panic("router: invalid compression level " + strconv.Itoa(level))
+    }
+    if len(types) == 0 {
+        types = CompressTypes
+    }
+
+    pools := map[string]*sync.Pool{
+        "gzip": {New: func() interface{} {
+            w, _ := gzip.NewWriterLevel(nil, level)
+            return w
+        }},
+        "deflate": {New: func() interface{} {
+            w, _ := flate.NewWriter(nil, level)
+            return w
+        }},
+    }
+
+    return func(httpCtx *Context) {
+        httpCtx.Output.Header().Add("Vary", "Accept-Encoding")
+
+        encoding := acceptEncoding(httpCtx.Input.Header.Get("Accept-Encoding"))
+        if encoding == "" || httpCtx.Input.Method == http.MethodHead || httpCtx.Input.Header.Get("Range") != "" ||
+            httpCtx.Input.Header.Get("Upgrade") != "" {
+            httpCtx.Next()
+
+            return
+        }
+
+        cw := &compressWriter{
+            ResponseWriter: httpCtx.Output,
+            encoding:       encoding,
+            pool:           pools[encoding],
+            minSize:        minSize,
+            types:          types,
+        }
+        hold(httpCtx, cw, httpCtx.Next)
+    }
+}
+
+// acceptEncoding returns gzip or deflate if it is accepted, gzip is preferred
+func acceptEncoding(header string) string {
+    accepted := make(map[string]bool)
+    for _, part := range strings.Split(header, ",") {
+        fields := strings.Split(part, ";")
+        coding := strings.ToLower(strings.TrimSpace(fields[0]))
+        q := 1.0
+        for _, param := range fields[1:] {
+            param = strings.TrimSpace(param)
+            if strings.HasPrefix(param, "q=") {
+                q, _ = strconv.ParseFloat(param[2:], 64)
+            }
+        }
+        accepted[coding] = q > 0
+    }
+
+    for _, coding := range []string{"gzip", "deflate"} {
+        if ok, exist := accepted[coding]; ok || (!exist && accepted["*"]) {
+            return coding
+        }
+    }
+
+    return ""
+}
+
+// compressWriter holds the body until minSize bytes are written or it is flushed,
+// then decides whether to compress it by the status and headers
+type compressWriter struct {
+    http.ResponseWriter
+    encoding    string
+    pool        *sync.Pool
+    minSize     int
+    types       []string
+    status      int
+    wroteHeader bool
+    decided     bool
+    buf         []byte
+    encoder     io.WriteCloser
+}
+
+// encoderWriter is implemented by gzip.Writer and flate.Writer
+type encoderWriter interface {
+    io.WriteCloser
+    Flush() error
+    Reset(w io.Writer)
+}
+
+// WriteHeader
+func (cw *compressWriter) WriteHeader(code int) {
+    if cw.wroteHeader {
+        return
+    }
+
+    cw.status = code
+    cw.wroteHeader = true
+}
+
+// Write
+func (cw *compressWriter) Write(b []byte) (int, error) {
+    if !cw.wroteHeader {
+        cw.WriteHeader(http.StatusOK)
+    }
+
+    if !cw.decided {
+        cw.buf = append(cw.buf, b...)
+        if len(cw.buf) < cw.minSize {
+            return len(b), nil
+        }
+
+        if err := cw.decide(true); err != nil {
+            return 0, err
+        }
+
+        return len(b), nil
+    }
+
+    if cw.encoder != nil {
+        return cw.encoder.Write(b)
+    }
+
+    return cw.ResponseWriter.Write(b)
+}
+
+// Flush decides to compress a streaming response even if it is smaller than minSize
+func (cw *compressWriter) Flush() {
+    if !cw.wroteHeader {
+        cw.WriteHeader(http.StatusOK)
+    }
+    if !cw.decided {
+        if err := cw.decide(true); err != nil {
+            return
+        }
+    }
+
+    if cw.encoder != nil {
+        _ = cw.encoder.(encoderWriter).Flush()
+    }
+    if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+        flusher.Flush()
+    }
+}
+
+// Commit writes the held body and finishes the compression
+func (cw *compressWriter) Commit() error {
+    if !cw.decided {
+        if !cw.wroteHeader {
+            return nil
+        }
+
+        if err := cw.decide(len(cw.buf) >= cw.minSize); err != nil {
+            return err
+        }
+    }
+
+    if cw.encoder != nil {
+        err := cw.encoder.Close()
+        cw.pool.Put(cw.encoder)
+        cw.encoder = nil
+
+        return err
+    }
+
+    return nil
+}
+
+// Unwrap returns the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+    return cw.ResponseWriter
+}
+
+// discard the held body and leave the compressed stream unfinished
+func (cw *compressWriter) discard() {
+    cw.buf = nil
+    if cw.encoder != nil {
+        cw.pool.Put(cw.encoder)
+        cw.encoder = nil
+    }
+}
+
+// decide whether to compress, then write the status and the held body
+func (cw *compressWriter) decide(large bool) (err error) {
+    cw.decided = true
+    header := cw.Header()
+    if header.Get("Content-Type") == "" && len(cw.buf) > 0 {
+        header.Set("Content-Type", http.DetectContentType(cw.buf))
+    }
+
+    if large && cw.compressible() {
+        header.Del("Content-Length")
+        header.Set("Content-Encoding", cw.encoding)
+        // the compressed body is not byte-for-byte identical to the one the strong ETag validates
+        if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+            header.Set("ETag", "W/"+etag)
+        }
+        encoder := cw.pool.Get().(encoderWriter)
+        encoder.Reset(cw.ResponseWriter)
+        cw.encoder = encoder
+    }
+
+    cw.ResponseWriter.WriteHeader(cw.status)
+    if len(cw.buf) > 0 {
+        if cw.encoder != nil {
+            _, err = cw.encoder.Write(cw.buf)
+        } else {
+            _, err = cw.ResponseWriter.Write(cw.buf)
+        }
+    }
+    cw.buf = nil
+
+    return
+}
+
+// compressible reports whether the response can be compressed by its status and headers
+func (cw *compressWriter) compressible() bool {
+    if cw.status < http.StatusOK || cw.status == http.StatusNoContent ||
+        cw.status == http.StatusPartialContent || cw.status == http.StatusNotModified {
+        return false
+    }
+
+    header := cw.Header()
+    if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+        return false
+    }
+
+    mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+    if err != nil || mediaType == "text/event-stream" {
+        return false
+    }
+
+    for _, t := range cw.types {
+        if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+            return true
+        }
+    }
+
+    return false
+}
@@ -0,0 +1,99 @@
+package router
+
+import (
+    "compress/flate"
+    "compress/gzip"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestMiddlewareCompress(t *testing.T) {
+    long := strings.Repeat("journey ", 64)
+    router := NewRouter()
+    router.Group("/").Use(MiddlewareCompress(gzip.DefaultCompression, 256))
+    router.Get("/text", func(httpCtx *Context) {
+        httpCtx.Text(http.StatusOK, []byte(long))
+    })
+    router.Get("/short", func(httpCtx *Context) {
+        httpCtx.Text(http.StatusOK, []byte("journey"))
+    })
+    router.Get("/image", func(httpCtx *Context) {
+        httpCtx.SetHeader("Content-Type", "image/png")
+        _, _ = httpCtx.Output.Write([]byte(long))
+    })
+    router.Get("/stream", func(httpCtx *Context) {
+        httpCtx.SetHeader("Content-Type", "text/plain")
+        _, _ = httpCtx.Output.Write([]byte("journey"))
+        httpCtx.Output.(http.Flusher).Flush()
+    })
+    router.Get("/events", func(httpCtx *Context) {
+        stream, _ := httpCtx.EventStream()
+        _ = stream.Send(&Event{Data: long})
+    })
+
+    tests := []struct {
+        name     string
+        method   string
+        uri      string
+        accept   string
+        header   map[string]string
+        encoding string
+        body     string
+    }{
+        {name: "gzip", method: http.MethodGet, uri: "/text", accept: "gzip, deflate", encoding: "gzip", body: long},
+        {name: "deflate", method: http.MethodGet, uri: "/text", accept: "gzip;q=0, deflate", encoding: "deflate", body: long},
+        {name: "identity", method: http.MethodGet, uri: "/text", accept: "identity", body: long},
+        {name: "short", method: http.MethodGet, uri: "/short", accept: "gzip", body: "journey"},
+        {name: "image", method: http.MethodGet, uri: "/image", accept: "gzip", body: long},
+        {name: "stream", method: http.MethodGet, uri: "/stream", accept: "*", encoding: "gzip", body: "journey"},
+        {name: "events", method: http.MethodGet, uri: "/events", accept: "gzip", body: "data: " + long + "\n\n"},
+        {name: "range", method: http.MethodGet, uri: "/text", accept: "gzip", header: map[string]string{"Range": "bytes=0-1"}, body: long},
+        {name: "head", method: http.MethodHead, uri: "/text", accept: "gzip", body: long},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(tt.method, tt.uri, nil)
+            r.Header.Set("Accept-Encoding", tt.accept)
+            for key, value := range tt.header {
+                r.Header.Set(key, value)
+            }
+            router.ServeHTTP(w, r)
+
+            if encoding := w.Header().Get("Content-Encoding"); encoding != tt.encoding {
+                t.Fatalf("Content-Encoding = %v, want %v", encoding, tt.encoding)
+            }
+            if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+                t.Errorf("Vary = %v, want Accept-Encoding", vary)
+            }
+
+            var reader io.Reader = w.Body
+            switch tt.encoding {
+            case "gzip":
+                reader, _ = gzip.NewReader(w.Body)
+            case "deflate":
+                reader = flate.NewReader(w.Body)
+            }
+            if body, err := ioutil.ReadAll(reader); err != nil || string(body) != tt.body {
+                t.Errorf("body = %v %v, want %v", string(body), err, tt.body)
+            }
+        })
+    }
+}
+
+func TestMiddlewareCompressLevel(t *testing.T) {
+    for _, level := range []int{gzip.HuffmanOnly, gzip.DefaultCompression, gzip.BestCompression, 10, -3} {
+        func() {
+            defer func() {
+                if e := recover(); (e != nil) != (level > gzip.BestCompression || level < gzip.HuffmanOnly) {
+                    t.Errorf("MiddlewareCompress(%v) panic = %v", level, e)
+                }
+            }()
+            MiddlewareCompress(level, 0)
+        }()
+    }
+}
@@ -2,28 +2,44 @@ package router
 
 import (
     "bytes"
+    "context"
     "encoding/json"
     "fmt"
+    "github.com/lanseyujie/journey/jwt"
+    "github.com/lanseyujie/journey/log"
     "io/ioutil"
     "net"
     "net/http"
     "strconv"
     "strings"
+    "sync"
     "time"
 )
 
-var errorHandler = make(map[int]HandlerFunc)
+var (
+    errorHandler = make(map[int]HandlerFunc)
+    errorMutex   sync.RWMutex
+)
 
 type H map[string]interface{}
 
 // Context is the router context
 type Context struct {
-    Input    *http.Request
-    Output   http.ResponseWriter
-    index    int8
-    handlers HandlersChain
-    params   *[]Param
-    code     int
+    Input     *http.Request
+    Output    http.ResponseWriter
+    router    *Router
+    response  *Response
+    index     int8
+    handlers  HandlersChain
+    params    *[]Param
+    code      int
+    csrfToken string
+    cspNonce  string
+    session   *Session
+    token     *jwt.Jwt
+    values    map[string]interface{}
+    trace     *log.Trace
+    untimed   context.Context // the request context before MiddlewareTimeout
 }
 
 // NewContext returns a new router context
@@ -31,9 +47,10 @@ func NewContext() *Context {
     params := make([]Param, 0, 4)
 
     return &Context{
-        code:   http.StatusOK,
-        index:  -1,
-        params: &params,
+        response: &Response{},
+        code:     http.StatusOK,
+        index:    -1,
+        params:   &params,
     }
 }
 
@@ -42,6 +59,13 @@ func (ctx *Context) reset() {
     ctx.handlers = nil
     *ctx.params = (*ctx.params)[0:0]
     ctx.code = http.StatusOK
+    ctx.csrfToken = ""
+    ctx.cspNonce = ""
+    ctx.session = nil
+    ctx.token = nil
+    ctx.values = nil
+    ctx.trace = nil
+    ctx.untimed = nil
 }
 
 func (ctx *Context) Next() {
@@ -51,19 +75,41 @@ func (ctx *Context) Next() {
     }
 }
 
-// GetHost returns request host
+// GetHost returns request host without the port, the brackets of an IPv6 literal are removed,
+// the Forwarded or X-Forwarded-Host header is used if the request comes from a trusted proxy
 func (ctx *Context) GetHost() string {
-    return strings.Split(ctx.Input.Host, ":")[0]
+    host, _ := ctx.host()
+
+    return host
+}
+
+// GetPort returns request port, the default port of the scheme if absent
+func (ctx *Context) GetPort() int {
+    _, port := ctx.host()
+
+    return port
 }
 
-// GetPort returns request port
-func (ctx *Context) GetPort() (port int) {
-    if socket := strings.Split(ctx.Input.Host, ":"); len(socket) == 2 {
-        port, _ = strconv.Atoi(socket[1])
-    } else if ctx.Input.TLS != nil {
-        port = 443
-    } else {
-        port = 80
+// host returns the host and port requested by the client
+func (ctx *Context) host() (host string, port int) {
+    hostport := ctx.Input.Host
+    trusted := ctx.trusted(ctx.remoteIp())
+    if trusted {
+        if h := ctx.forwardedParam("host", "X-Forwarded-Host"); h != "" {
+            hostport = h
+        }
+    }
+
+    host, port = splitHost(hostport)
+    if port == 0 && trusted {
+        port, _ = strconv.Atoi(ctx.forwardedHeader("X-Forwarded-Port"))
+    }
+    if port == 0 {
+        if ctx.GetScheme() == "https" {
+            port = 443
+        } else {
+            port = 80
+        }
     }
 
     return
@@ -74,9 +120,19 @@ func (ctx *Context) GetMethod() string {
     return ctx.Input.Method
 }
 
-// GetScheme returns request scheme
+// GetScheme returns request scheme, http or https,
+// the Forwarded or X-Forwarded-Proto header is used if the request comes from a trusted proxy,
+// the value is taken from the proxy the client connected to, see GetClientIp
 func (ctx *Context) GetScheme() string {
-    return ctx.Input.URL.Scheme
+    if ctx.Input.TLS != nil {
+        return "https"
+    }
+
+    if ctx.trusted(ctx.remoteIp()) && strings.EqualFold(ctx.forwardedParam("proto", "X-Forwarded-Proto"), "https") {
+        return "https"
+    }
+
+    return "http"
 }
 
 // GetUri returns request uri
@@ -95,14 +151,19 @@ func (ctx *Context) GetParams(key string) (value string, exist bool) {
     return
 }
 
+// addParam appends a matched path parameter
+func (ctx *Context) addParam(key, value string) {
+    *ctx.params = append(*ctx.params, Param{Key: key, Value: value})
+}
+
 // GetHeader
 func (ctx *Context) GetHeader(key string) string {
     return ctx.Input.Header.Get(key)
 }
 
-// GetAuth
+// GetAuth returns the Authorization header
 func (ctx *Context) GetAuth() string {
-    return ctx.Input.Header.Get("Authentication")
+    return ctx.Input.Header.Get("Authorization")
 }
 
 // GetReferer
@@ -115,23 +176,116 @@ func (ctx *Context) GetUserAgent() string {
     return ctx.Input.UserAgent()
 }
 
-// GetClientIp
+// GetClientIp returns the ip of the client, the Forwarded, X-Forwarded-For or X-Real-Ip header is used
+// if the request comes from a trusted proxy, the trusted proxies in the chain are skipped from right to left
 func (ctx *Context) GetClientIp() string {
-    ip := strings.TrimSpace(strings.Split(ctx.Input.Header.Get("X-Forwarded-For"), ",")[0])
-    if ip != "" {
-        return ip
+    remote := ctx.remoteIp()
+    if remote == nil {
+        return ""
+    }
+    if !ctx.trusted(remote) {
+        return remote.String()
+    }
+
+    var chain []string
+    if elements := ctx.forwarded(); len(elements) > 0 {
+        chain = forwardedFor(elements)
+    } else if chain = headerValues(ctx.Input.Header.Values("X-Forwarded-For")); len(chain) == 0 {
+        if ip := net.ParseIP(strings.TrimSpace(ctx.Input.Header.Get("X-Real-Ip"))); ip != nil {
+            return ip.String()
+        }
+    }
+
+    if len(chain) > 0 {
+        if ip := nodeIp(chain[ctx.clientHop(chain)]); ip != nil {
+            return ip.String()
+        }
     }
 
-    ip = strings.TrimSpace(ctx.Input.Header.Get("X-Real-Ip"))
-    if ip != "" {
-        return ip
+    return remote.String()
+}
+
+// clientHop returns the index of the node the client ip is taken from, the trusted proxies are skipped from right to left,
+// the rightmost one if it is unknown
+func (ctx *Context) clientHop(chain []string) int {
+    hop := len(chain) - 1
+    for i := len(chain) - 1; i >= 0; i-- {
+        ip := nodeIp(chain[i])
+        if ip == nil {
+            break
+        }
+        hop = i
+        if !ctx.trusted(ip) {
+            break
+        }
     }
 
-    if ip, _, err := net.SplitHostPort(strings.TrimSpace(ctx.Input.RemoteAddr)); err == nil {
-        return ip
+    return hop
+}
+
+// remoteIp returns the ip of the peer
+func (ctx *Context) remoteIp() net.IP {
+    if host, _, err := net.SplitHostPort(strings.TrimSpace(ctx.Input.RemoteAddr)); err == nil {
+        return net.ParseIP(host)
     }
 
-    return ""
+    return net.ParseIP(strings.TrimSpace(ctx.Input.RemoteAddr))
+}
+
+// trusted reports whether the ip is a trusted proxy of the router
+func (ctx *Context) trusted(ip net.IP) bool {
+    return ctx.router != nil && ctx.router.trustedProxy(ip)
+}
+
+// forwarded returns the elements of the Forwarded header
+func (ctx *Context) forwarded() []map[string]string {
+    return parseForwarded(ctx.Input.Header.Values("Forwarded"))
+}
+
+// forwardedParam returns the parameter of the Forwarded element added by the proxy the client connected to,
+// or the X-Forwarded header if there is no Forwarded header
+func (ctx *Context) forwardedParam(param, header string) string {
+    if elements := ctx.forwarded(); len(elements) > 0 {
+        return elements[ctx.clientHop(forwardedFor(elements))][param]
+    }
+
+    return ctx.forwardedHeader(header)
+}
+
+// forwardedHeader returns the value of the X-Forwarded header added by the proxy the client connected to
+// if the values line up with X-Forwarded-For, otherwise the rightmost one added by the nearest proxy
+func (ctx *Context) forwardedHeader(header string) string {
+    values := headerValues(ctx.Input.Header.Values(header))
+    if len(values) == 0 {
+        return ""
+    }
+    if chain := headerValues(ctx.Input.Header.Values("X-Forwarded-For")); len(chain) == len(values) {
+        return values[ctx.clientHop(chain)]
+    }
+
+    return values[len(values)-1]
+}
+
+// forwardedFor returns the for parameters of the Forwarded elements
+func forwardedFor(elements []map[string]string) []string {
+    chain := make([]string, len(elements))
+    for i, element := range elements {
+        chain[i] = element["for"]
+    }
+
+    return chain
+}
+
+// headerValues returns the values of comma separated headers
+func headerValues(values []string) []string {
+    var list []string
+    for _, value := range values {
+        for _, v := range strings.Split(value, ",") {
+            list = append(list, strings.TrimSpace(v))
+        }
+    }
+
+    return list
 }
 
 // GetQuery returns a GET request parameter
@@ -213,7 +367,7 @@ func (ctx *Context) Text(code int, text []byte) {
     _, _ = ctx.Output.Write(text)
 }
 
-// StatusCode
+// StatusCode writes the status code, it is ignored if the status code has been written
 func (ctx *Context) StatusCode(code int) {
     ctx.code = code
     ctx.Output.WriteHeader(ctx.code)
@@ -224,6 +378,13 @@ func (ctx *Context) SetHeader(key, value string) {
     ctx.Output.Header().Set(key, value)
 }
 
+// LastModified sets the Last-Modified header, e.g. the update time of a post, see MiddlewareETag
+func (ctx *Context) LastModified(t time.Time) {
+    if !t.IsZero() {
+        ctx.Output.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+    }
+}
+
 // Redirect response
 func (ctx *Context) Redirect(code int, url string) {
     if 300 < code && code < 400 {
@@ -232,19 +393,26 @@ func (ctx *Context) Redirect(code int, url string) {
     }
 }
 
-// Error page response
+// Error page response, the error handler of the router group is preferred,
+// then the error handler of the router and the global one
 func (ctx *Context) Error(code int) {
-    if handler, exist := errorHandler[code]; exist {
-        handler(ctx)
-    } else {
-        ctx.StatusCode(code)
-        _, _ = ctx.Output.Write([]byte(http.StatusText(code)))
+    if ctx.router != nil {
+        if handler := ctx.router.getErrorHandler(ctx.Input.URL.Path, code); handler != nil {
+            handler(ctx)
+
+            return
+        }
     }
+
+    GetErrorHandler(code)(ctx)
 }
 
-// GetErrorHandler returns the specified error handler
+// GetErrorHandler returns the specified global error handler
 func GetErrorHandler(code int) HandlerFunc {
-    if handler, exist := errorHandler[code]; exist {
+    errorMutex.RLock()
+    handler, exist := errorHandler[code]
+    errorMutex.RUnlock()
+    if exist {
         return handler
     }
 
@@ -254,10 +422,19 @@ func GetErrorHandler(code int) HandlerFunc {
     }
 }
 
-// SetErrorHandler for custom error handler
+// SetErrorHandler for custom global error handler
 func SetErrorHandler(code int, handler HandlerFunc) {
     if handler != nil {
+        errorMutex.Lock()
         errorHandler[code] = handler
+        errorMutex.Unlock()
+    }
+}
+
+// errorFunc returns a handler responding the error page when called
+func errorFunc(code int) HandlerFunc {
+    return func(ctx *Context) {
+        ctx.Error(code)
     }
 }
 
@@ -266,14 +443,35 @@ func (ctx *Context) Handler(handler HandlerFunc) {
     handler(ctx)
 }
 
-// GetStatusCode
+// GetStatusCode returns the status code sent to the client
 func (ctx *Context) GetStatusCode() int {
+    if ctx.response.Written() {
+        return ctx.response.Status()
+    }
+
     return ctx.code
 }
 
+// Response returns the response sent to the client
+func (ctx *Context) Response() *Response {
+    return ctx.response
+}
+
+// Buffer the response until the returned response is committed,
+// e.g. to replace the response after calling the next middleware
+func (ctx *Context) Buffer() *Response {
+    buffered := NewBufferedResponse(ctx.Output)
+    ctx.Output = buffered
+
+    return buffered
+}
+
 // Logger
 func (ctx *Context) Logger() string {
-    return fmt.Sprintf("%s %s %s %s %d %s", ctx.GetClientIp(), ctx.Input.Method, ctx.Input.Host, ctx.Input.URL, ctx.code, ctx.Input.UserAgent())
+    scheme := ctx.GetScheme()
+    host, port := ctx.host()
+
+    return fmt.Sprintf("%s %s %s://%s %s %d %d %s", ctx.GetClientIp(), ctx.Input.Method, scheme, joinHost(scheme, host, port), ctx.Input.URL, ctx.GetStatusCode(), ctx.response.Size(), ctx.Input.UserAgent())
 }
 
 // GetCookie
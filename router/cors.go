@@ -0,0 +1,167 @@
+package router
+
+import (
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Cors is a cross-origin resource sharing policy, register its middleware on the root group
+// so that the preflight requests answered automatically are covered as well
+type Cors struct {
+    AllowOrigins     []string // e.g. https://example.com, *.example.com for the subdomains of any scheme, * for all
+    AllowMethods     []string // GET, HEAD, POST, PUT, PATCH and DELETE if empty
+    AllowHeaders     []string // the request headers allowed, the requested ones are allowed if empty
+    ExposeHeaders    []string // the response headers readable by the client script
+    AllowCredentials bool
+    MaxAge           time.Duration // how long the preflight result can be cached, not sent if 0
+}
+
+// DefaultCorsMethods
+var DefaultCorsMethods = []string{
+    http.MethodGet,
+    http.MethodHead,
+    http.MethodPost,
+    http.MethodPut,
+    http.MethodPatch,
+    http.MethodDelete,
+}
+
+// MiddlewareCors allows the origins in domain, see Cors
+func MiddlewareCors(domain []string) HandlerFunc {
+    return (&Cors{AllowOrigins: domain}).Middleware()
+}
+
+// Middleware answers the preflight requests and sets the CORS headers of the actual requests
+func (c *Cors) Middleware() HandlerFunc {
+    methods := c.AllowMethods
+    if len(methods) == 0 {
+        methods = DefaultCorsMethods
+    }
+
+    return func(httpCtx *Context) {
+        header := httpCtx.Output.Header()
+        origin := httpCtx.Input.Header.Get("Origin")
+        requestMethod := httpCtx.Input.Header.Get("Access-Control-Request-Method")
+        preflight := httpCtx.Input.Method == http.MethodOptions && requestMethod != ""
+
+        header.Add("Vary", "Origin")
+        if preflight {
+            header.Add("Vary", "Access-Control-Request-Method")
+            header.Add("Vary", "Access-Control-Request-Headers")
+        }
+
+        if origin == "" || !c.AllowOrigin(origin) {
+            if preflight {
+                httpCtx.StatusCode(http.StatusNoContent)
+            } else {
+                httpCtx.Next()
+            }
+
+            return
+        }
+
+        if !preflight {
+            c.setOrigin(header, origin)
+            if len(c.ExposeHeaders) > 0 {
+                header.Set("Access-Control-Expose-Headers", strings.Join(c.ExposeHeaders, ", "))
+            }
+            httpCtx.Next()
+
+            return
+        }
+
+        requestHeaders := httpCtx.Input.Header.Get("Access-Control-Request-Headers")
+        if contains(methods, requestMethod) && c.allowHeaders(requestHeaders) {
+            c.setOrigin(header, origin)
+            header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+            if requestHeaders != "" {
+                header.Set("Access-Control-Allow-Headers", requestHeaders)
+            }
+            if c.MaxAge > 0 {
+                header.Set("Access-Control-Max-Age", strconv.FormatInt(int64(c.MaxAge/time.Second), 10))
+            }
+        }
+        httpCtx.StatusCode(http.StatusNoContent)
+    }
+}
+
+// AllowOrigin reports whether the origin is allowed
+func (c *Cors) AllowOrigin(origin string) bool {
+    u, err := url.Parse(strings.ToLower(origin))
+    if err != nil || u.Scheme == "" || u.Host == "" {
+        return false
+    }
+
+    for _, allowed := range c.AllowOrigins {
+        allowed = strings.ToLower(allowed)
+        switch {
+        case allowed == "*" || allowed == u.Scheme+"://"+u.Host:
+            return true
+        case strings.HasPrefix(allowed, "*."):
+            if strings.HasSuffix(u.Host, allowed[1:]) {
+                return true
+            }
+        case strings.HasPrefix(allowed, u.Scheme+"://*."):
+            if strings.HasSuffix(u.Host, allowed[len(u.Scheme)+4:]) {
+                return true
+            }
+        }
+    }
+
+    return false
+}
+
+// setOrigin sets the allowed origin, * is only sent to the requests without credentials
+func (c *Cors) setOrigin(header http.Header, origin string) {
+    if !c.AllowCredentials && contains(c.AllowOrigins, "*") {
+        header.Set("Access-Control-Allow-Origin", "*")
+
+        return
+    }
+
+    header.Set("Access-Control-Allow-Origin", origin)
+    if c.AllowCredentials {
+        header.Set("Access-Control-Allow-Credentials", "true")
+    }
+}
+
+// allowHeaders reports whether all the requested headers are allowed
+func (c *Cors) allowHeaders(requestHeaders string) bool {
+    if len(c.AllowHeaders) == 0 || requestHeaders == "" {
+        return true
+    }
+
+    for _, name := range strings.Split(requestHeaders, ",") {
+        name = strings.TrimSpace(name)
+        if name == "" {
+            continue
+        }
+
+        allowed := false
+        for _, value := range c.AllowHeaders {
+            if strings.EqualFold(value, name) {
+                allowed = true
+                break
+            }
+        }
+        if !allowed {
+            return false
+        }
+    }
+
+    return true
+}
+
+// contains reports whether the value is in the list
+func contains(list []string, value string) bool {
+    for _, v := range list {
+        if v == value {
+            return true
+        }
+    }
+
+    return false
+}
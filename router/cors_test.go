@@ -0,0 +1,88 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestCors(t *testing.T) {
+    cors := &Cors{
+        AllowOrigins:     []string{"https://example.com", "*.journey.dev"},
+        AllowHeaders:     []string{"Content-Type", "X-Token"},
+        ExposeHeaders:    []string{"X-Total"},
+        AllowCredentials: true,
+        MaxAge:           time.Hour,
+    }
+    router := NewRouter()
+    router.Group("/").Use(cors.Middleware())
+    router.Get("/post", func(httpCtx *Context) {
+        httpCtx.Text(http.StatusOK, []byte("post"))
+    })
+
+    tests := []struct {
+        name    string
+        method  string
+        origin  string
+        request string // Access-Control-Request-Method
+        headers string // Access-Control-Request-Headers
+        code    int
+        allow   string
+        maxAge  string
+        expose  string
+    }{
+        {name: "1", method: http.MethodGet, origin: "https://example.com", code: http.StatusOK, allow: "https://example.com", expose: "X-Total"},
+        {name: "2", method: http.MethodGet, origin: "https://notexample.com", code: http.StatusOK},
+        {name: "3", method: http.MethodGet, origin: "https://example.com.evil.com", code: http.StatusOK},
+        {name: "4", method: http.MethodGet, origin: "http://blog.journey.dev", code: http.StatusOK, allow: "http://blog.journey.dev", expose: "X-Total"},
+        {name: "5", method: http.MethodGet, origin: "https://journey.dev", code: http.StatusOK},
+        {name: "6", method: http.MethodOptions, origin: "https://example.com", request: http.MethodPut, headers: "content-type", code: http.StatusNoContent, allow: "https://example.com", maxAge: "3600"},
+        {name: "7", method: http.MethodOptions, origin: "https://example.com", request: http.MethodPut, headers: "X-Other", code: http.StatusNoContent},
+        {name: "8", method: http.MethodOptions, origin: "https://example.com", request: "PURGE", code: http.StatusNoContent},
+        {name: "9", method: http.MethodOptions, origin: "https://evil.com", request: http.MethodGet, code: http.StatusNoContent},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(tt.method, "/post", nil)
+            r.Header.Set("Origin", tt.origin)
+            if tt.request != "" {
+                r.Header.Set("Access-Control-Request-Method", tt.request)
+            }
+            if tt.headers != "" {
+                r.Header.Set("Access-Control-Request-Headers", tt.headers)
+            }
+            router.ServeHTTP(w, r)
+
+            header := w.Header()
+            if w.Code != tt.code || header.Get("Access-Control-Allow-Origin") != tt.allow {
+                t.Fatalf("ServeHTTP() = %v %v, want %v %v", w.Code, header.Get("Access-Control-Allow-Origin"), tt.code, tt.allow)
+            }
+            if header.Get("Access-Control-Max-Age") != tt.maxAge || header.Get("Access-Control-Expose-Headers") != tt.expose {
+                t.Errorf("Max-Age, Expose-Headers = %v %v, want %v %v", header.Get("Access-Control-Max-Age"),
+                    header.Get("Access-Control-Expose-Headers"), tt.maxAge, tt.expose)
+            }
+            if credentials := header.Get("Access-Control-Allow-Credentials"); (tt.allow != "") != (credentials == "true") {
+                t.Errorf("Access-Control-Allow-Credentials = %v", credentials)
+            }
+            if vary := header.Get("Vary"); vary != "Origin" {
+                t.Errorf("Vary = %v, want Origin", vary)
+            }
+        })
+    }
+
+    // * is sent without credentials
+    all := NewRouter()
+    all.Group("/").Use(MiddlewareCors([]string{"*"}))
+    all.Get("/post", func(httpCtx *Context) {
+        httpCtx.Text(http.StatusOK, []byte("post"))
+    })
+    w := httptest.NewRecorder()
+    r := httptest.NewRequest(http.MethodGet, "/post", nil)
+    r.Header.Set("Origin", "https://any.com")
+    all.ServeHTTP(w, r)
+    if allow := w.Header().Get("Access-Control-Allow-Origin"); allow != "*" {
+        t.Errorf("Access-Control-Allow-Origin = %v, want *", allow)
+    }
+}
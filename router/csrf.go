@@ -0,0 +1,138 @@
+package router
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "github.com/lanseyujie/journey/utils"
+    "mime"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// Csrf protects the unsafe requests against cross-site request forgery,
+// the token is signed and kept in a cookie to be submitted again by the form field or header (double submit),
+// or derived from the session id if Session is set (per session), see Context.CsrfToken
+type Csrf struct {
+    secret     []byte
+    CookieName string // _csrf by default
+    FieldName  string // _csrf by default, the same as the theme function csrffield
+    HeaderName string // X-CSRF-Token by default
+    Path       string
+    Domain     string
+    Secure     bool
+    SameSite   http.SameSite
+    MaxAge     time.Duration
+    // Session returns the session id of the request, the double submit cookie is used if it returns empty
+    Session func(httpCtx *Context) string
+}
+
+// NewCsrf
+func NewCsrf(secret string) *Csrf {
+    return &Csrf{
+        secret:     []byte(secret),
+        CookieName: "_csrf",
+        FieldName:  "_csrf",
+        HeaderName: "X-CSRF-Token",
+        Path:       "/",
+        SameSite:   http.SameSiteLaxMode,
+        MaxAge:     12 * time.Hour,
+    }
+}
+
+// CsrfToken returns the token to submit with the unsafe requests, empty if the csrf middleware is not used
+func (ctx *Context) CsrfToken() string {
+    return ctx.csrfToken
+}
+
+// Middleware issues the token and rejects the unsafe requests without a valid one by the 403 error handler,
+// multipart forms are parsed with DefaultMaxBodySize, send the token by the header for larger uploads
+func (c *Csrf) Middleware() HandlerFunc {
+    return func(httpCtx *Context) {
+        token := c.token(httpCtx)
+        httpCtx.csrfToken = token
+
+        switch httpCtx.Input.Method {
+        case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+            httpCtx.Next()
+
+            return
+        }
+
+        submitted := c.submitted(httpCtx)
+        if submitted == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+            httpCtx.Error(http.StatusForbidden)
+
+            return
+        }
+
+        httpCtx.Next()
+    }
+}
+
+// token returns the token of the session or the cookie, a new cookie is issued if it is absent or forged
+func (c *Csrf) token(httpCtx *Context) string {
+    if c.Session != nil {
+        if id := c.Session(httpCtx); id != "" {
+            return c.sign("session:" + id)
+        }
+    }
+
+    if cookie, err := httpCtx.Input.Cookie(c.CookieName); err == nil {
+        if i := strings.LastIndexByte(cookie.Value, '.'); i > 0 {
+            nonce := cookie.Value[:i]
+            if hmac.Equal([]byte(cookie.Value[i+1:]), []byte(c.sign(nonce))) {
+                return cookie.Value
+            }
+        }
+    }
+
+    nonce := string(utils.RandomBytes(32))
+    token := nonce + "." + c.sign(nonce)
+    httpCtx.SetCookie(&http.Cookie{
+        Name:     c.CookieName,
+        Value:    token,
+        Path:     c.Path,
+        Domain:   c.Domain,
+        MaxAge:   int(c.MaxAge / time.Second),
+        Secure:   c.Secure,
+        HttpOnly: true,
+        SameSite: c.SameSite,
+    })
+
+    return token
+}
+
+// submitted returns the token submitted by the header or the form field
+func (c *Csrf) submitted(httpCtx *Context) string {
+    if token := httpCtx.Input.Header.Get(c.HeaderName); token != "" {
+        return token
+    }
+
+    mediaType, _, _ := mime.ParseMediaType(httpCtx.Input.Header.Get("Content-Type"))
+    switch mediaType {
+    case "application/x-www-form-urlencoded":
+        return httpCtx.Input.PostFormValue(c.FieldName)
+    case "multipart/form-data":
+        if httpCtx.Input.MultipartForm == nil {
+            httpCtx.Input.Body = http.MaxBytesReader(httpCtx.Output, httpCtx.Input.Body, DefaultMaxBodySize)
+            if httpCtx.Input.ParseMultipartForm(DefaultMaxMemory) != nil {
+                return ""
+            }
+        }
+
+        return httpCtx.Input.PostFormValue(c.FieldName)
+    }
+
+    return ""
+}
+
+// sign returns the base64 encoded HMAC-SHA256 of the value
+func (c *Csrf) sign(value string) string {
+    mac := hmac.New(sha256.New, c.secret)
+    _, _ = mac.Write([]byte(value))
+
+    return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,87 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+)
+
+func TestCsrf(t *testing.T) {
+    csrf := NewCsrf("secret")
+    csrf.Session = func(httpCtx *Context) string {
+        return httpCtx.Input.Header.Get("X-Session")
+    }
+    router := NewRouter()
+    router.SetErrorHandler(http.StatusForbidden, func(httpCtx *Context) {
+        httpCtx.Text(http.StatusForbidden, []byte("csrf"))
+    })
+    router.Group("/").Use(csrf.Middleware())
+    router.Get("/comment", func(httpCtx *Context) {
+        httpCtx.Text(http.StatusOK, []byte(httpCtx.CsrfToken()))
+    })
+    router.Post("/comment", func(httpCtx *Context) {
+        httpCtx.Text(http.StatusOK, []byte("ok"))
+    })
+
+    // issue the token by the cookie
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/comment", nil))
+    cookies := w.Result().Cookies()
+    if len(cookies) != 1 || cookies[0].Value != w.Body.String() || !cookies[0].HttpOnly {
+        t.Fatalf("ServeHTTP() = %v %v", cookies, w.Body.String())
+    }
+    token := cookies[0].Value
+
+    // session token
+    w = httptest.NewRecorder()
+    r := httptest.NewRequest(http.MethodGet, "/comment", nil)
+    r.Header.Set("X-Session", "1")
+    router.ServeHTTP(w, r)
+    session := w.Body.String()
+    if session == "" || session == token || len(w.Result().Cookies()) != 0 {
+        t.Fatalf("ServeHTTP() = %v %v", w.Result().Cookies(), session)
+    }
+
+    tests := []struct {
+        name    string
+        cookie  string
+        session string
+        header  string
+        form    string
+        code    int
+    }{
+        {name: "1", cookie: token, code: http.StatusForbidden},
+        {name: "2", cookie: token, header: token, code: http.StatusOK},
+        {name: "3", cookie: token, form: token, code: http.StatusOK},
+        {name: "4", cookie: token, header: "forged", code: http.StatusForbidden},
+        // the cookie set by an attacker is not signed
+        {name: "5", cookie: "forged.token", header: "forged.token", code: http.StatusForbidden},
+        {name: "6", session: "1", header: session, code: http.StatusOK},
+        {name: "7", session: "2", header: session, code: http.StatusForbidden},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodPost, "/comment", strings.NewReader(url.Values{"_csrf": {tt.form}}.Encode()))
+            r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+            if tt.cookie != "" {
+                r.AddCookie(&http.Cookie{Name: "_csrf", Value: tt.cookie})
+            }
+            if tt.session != "" {
+                r.Header.Set("X-Session", tt.session)
+            }
+            if tt.header != "" {
+                r.Header.Set("X-CSRF-Token", tt.header)
+            }
+            router.ServeHTTP(w, r)
+            if w.Code != tt.code {
+                t.Errorf("ServeHTTP() = %v %v, want %v", w.Code, w.Body.String(), tt.code)
+            }
+            if tt.code == http.StatusForbidden && w.Body.String() != "csrf" {
+                t.Errorf("ServeHTTP() = %v, want the 403 error handler", w.Body.String())
+            }
+        })
+    }
+}
@@ -35,6 +35,13 @@ func (h *Helper) Use(middleware ...HandlerFunc) *Helper {
     return h
 }
 
+// SetErrorHandler for custom error handler of the group, it takes precedence over the one of the router
+func (h *Helper) SetErrorHandler(code int, handler HandlerFunc) *Helper {
+    h.router.setErrorHandler(h.group, code, handler)
+
+    return h
+}
+
 // Target
 func (h *Helper) Target(target string) *Helper {
     // make sure not to start with /
@@ -46,6 +53,13 @@ func (h *Helper) Target(target string) *Helper {
     return h
 }
 
+// Name the current target, e.g. Target("post/:id").Name("post").Get(handler)
+func (h *Helper) Name(name string) *Helper {
+    h.router.Name(name, h.group+h.target)
+
+    return h
+}
+
 // Head
 func (h *Helper) Head(handler HandlerFunc) *Helper {
     h.router.Head(h.group+h.target, handler)
@@ -88,6 +102,34 @@ func (h *Helper) Delete(handler HandlerFunc) *Helper {
     return h
 }
 
+// Patch
+func (h *Helper) Patch(handler HandlerFunc) *Helper {
+    h.router.Patch(h.group+h.target, handler)
+
+    return h
+}
+
+// Connect
+func (h *Helper) Connect(handler HandlerFunc) *Helper {
+    h.router.Connect(h.group+h.target, handler)
+
+    return h
+}
+
+// Trace
+func (h *Helper) Trace(handler HandlerFunc) *Helper {
+    h.router.Trace(h.group+h.target, handler)
+
+    return h
+}
+
+// Handle
+func (h *Helper) Handle(method string, handler HandlerFunc) *Helper {
+    h.router.Handle(method, h.group+h.target, handler)
+
+    return h
+}
+
 // Any
 func (h *Helper) Any(handler HandlerFunc) *Helper {
     h.router.Any(h.group+h.target, handler)
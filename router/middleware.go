@@ -2,146 +2,158 @@ package router
 
 import (
     "context"
-    "errors"
-    "fmt"
     "github.com/lanseyujie/journey/log"
     "github.com/lanseyujie/journey/utils"
     "net/http"
-    "net/http/httputil"
-    "strconv"
     "strings"
     "sync/atomic"
     "time"
 )
 
-// MiddlewareLogger
+// MiddlewareLogger logs the requests and recovers from the panics,
+// see MiddlewareAccessLog and MiddlewareRecovery to use them separately
 func MiddlewareLogger() HandlerFunc {
+    rc := NewRecovery(false, ReportLog)
+
     return func(httpCtx *Context) {
+        output := httpCtx.Output
         defer func() {
-            var err error
-            if e := recover(); e != nil {
-                switch e := e.(type) {
-                case string:
-                    err = errors.New(e)
-                case error:
-                    err = e
-                default:
-                    err = errors.New(fmt.Sprint(e))
-                }
-            }
-            if err != nil {
-                // print stack trace
-                // log.Println(err)
-                // debug.PrintStack()
-                log.Error(utils.StackTrace(err, 0))
-
-                // dump http request header
-                request, _ := httputil.DumpRequest(httpCtx.Input, false)
-                log.Debug(string(request))
-
-                // the default error page can be called in the following 3 ways
-                httpCtx.Error(http.StatusInternalServerError)
-                // GetErrorHandler(http.StatusInternalServerError)(httpCtx)
-                // httpCtx.Handler(router.GetErrorHandler(http.StatusInternalServerError))
-            }
-
             // reasons for collecting logs here:
             // 1. capture the response status code, error and running time
             // 2. avoid directly executing the defer process and skip log collection when panic occurs
-            log.Http(httpCtx.Logger())
-        }()
+            defer func() {
+                log.Http(httpCtx.Context(), httpCtx.Logger())
+            }()
 
-        // to do something before
+            if e := recover(); e != nil {
+                // skip runtime.Callers, utils.StackTrace, this function and runtime.gopanic
+                rc.handle(httpCtx, output, e, utils.StackTrace(panicError(e), 4))
+            }
+        }()
 
-        // call the next middleware
         httpCtx.Next()
+    }
+}
+
+// MiddlewareAccessLog logs the requests, including the ones of panics
+func MiddlewareAccessLog() HandlerFunc {
+    return func(httpCtx *Context) {
+        defer func() {
+            log.Http(httpCtx.Context(), httpCtx.Logger())
+        }()
 
-        // to do something after
+        httpCtx.Next()
     }
 }
 
-// MiddlewareTimeout
+// MiddlewareTimeout, the streams started by Context.EventStream are detached from the timeout
 func MiddlewareTimeout(d time.Duration) HandlerFunc {
     return func(httpCtx *Context) {
-        ctx, cancel := context.WithTimeout(httpCtx.Input.Context(), d)
+        parent := httpCtx.Input.Context()
+        ctx, cancel := context.WithTimeout(parent, d)
         defer cancel()
+        if httpCtx.untimed == nil {
+            httpCtx.untimed = parent
+        }
         httpCtx.Input = httpCtx.Input.WithContext(ctx)
 
         httpCtx.Next()
     }
 }
 
-// MiddlewareCors
-func MiddlewareCors(domain []string) HandlerFunc {
+// MiddlewareETag buffers the responses of GET and HEAD requests to set their ETag if the controller did not,
+// and responds 304 if If-None-Match or If-Modified-Since matches, see Context.LastModified,
+// register it after MiddlewareCompress so that the ETag is computed from the uncompressed body,
+// the flushed responses, e.g. event streams, are passed through, see Response.Flush
+func MiddlewareETag(weak bool) HandlerFunc {
     return func(httpCtx *Context) {
-        if httpCtx.Input.Method != http.MethodOptions {
+        method := httpCtx.Input.Method
+        if (method != http.MethodGet && method != http.MethodHead) || httpCtx.Input.Header.Get("Upgrade") != "" {
             httpCtx.Next()
 
             return
         }
 
-        allow := false
-        origin := httpCtx.Input.Header.Get("Origin")
-        method := []string{
-            http.MethodGet,
-            http.MethodPost,
-            http.MethodOptions,
-            http.MethodPut,
-            http.MethodPatch,
-            http.MethodDelete,
-        }
+        buffered := NewBufferedResponse(httpCtx.Output)
+        hold(httpCtx, buffered, func() {
+            httpCtx.Next()
+
+            if buffered.Status() != http.StatusOK || !buffered.Buffering() {
+                return
+            }
 
-        if len(domain) > 0 {
-            if len(domain) == 1 && domain[0] == "*" {
-                allow = true
-                origin = "*"
-            } else if len(origin) > 0 {
-                for _, value := range domain {
-                    if strings.Contains(value, origin) {
-                        allow = true
-                        break
-                    }
+            header := buffered.Header()
+            if header.Get("ETag") == "" {
+                sum, err := utils.Sha1sum(buffered.Body())
+                if err != nil {
+                    return
+                }
+                etag := `"` + sum + `"`
+                if weak {
+                    etag = "W/" + etag
                 }
+                header.Set("ETag", etag)
             }
-        }
 
-        if allow {
-            httpCtx.Output.Header().Set("Access-Control-Allow-Origin", origin)
-            if httpCtx.Input.Method == http.MethodOptions {
-                httpCtx.Output.Header().Set("Access-Control-Allow-Methods", strings.Join(method, ", "))
-                httpCtx.Output.Header().Set("Access-Control-Allow-Headers", httpCtx.Input.Header.Get("Access-Control-Request-Headers"))
+            if notModified(httpCtx.Input, header) {
+                _ = buffered.Reset()
+                header.Del("Content-Type")
+                header.Del("Content-Length")
+                buffered.WriteHeader(http.StatusNotModified)
+            }
+        })
+    }
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since of the request,
+// If-Modified-Since is ignored if If-None-Match is present
+func notModified(req *http.Request, header http.Header) bool {
+    if inm := req.Header.Get("If-None-Match"); inm != "" {
+        etag := strings.TrimPrefix(header.Get("ETag"), "W/")
+        for _, tag := range strings.Split(inm, ",") {
+            tag = strings.TrimSpace(tag)
+            if tag == "*" || (etag != "" && strings.TrimPrefix(tag, "W/") == etag) {
+                return true
             }
-            httpCtx.StatusCode(http.StatusNoContent)
         }
+
+        return false
+    }
+
+    ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+    if err != nil {
+        return false
+    }
+    lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+    if err != nil {
+        return false
     }
+
+    return !lastModified.Truncate(time.Second).After(ims)
 }
 
-// MiddlewareHttpsUpgrade
+// MiddlewareHttpsUpgrade redirects the http requests to https on the port,
+// the requests forwarded by a trusted proxy over https are not redirected
 func MiddlewareHttpsUpgrade(port int) HandlerFunc {
     return func(httpCtx *Context) {
-        if httpCtx.Input.TLS == nil {
-            url := "https://" + httpCtx.GetHost()
-            if port != 443 {
-                url += ":" + strconv.FormatInt(int64(port), 10) + httpCtx.Input.URL.Path
-            }
-            if len(httpCtx.Input.URL.RawQuery) > 0 {
-                url += httpCtx.Input.URL.Path + "?" + httpCtx.Input.URL.RawQuery
-            } else {
-                url += httpCtx.Input.URL.Path
-            }
-            httpCtx.Redirect(http.StatusMovedPermanently, url)
-        } else {
+        if httpCtx.GetScheme() == "https" {
             httpCtx.Next()
+
+            return
         }
+
+        url := "https://" + joinHost("https", httpCtx.GetHost(), port) + httpCtx.Input.URL.RequestURI()
+        httpCtx.Redirect(http.StatusMovedPermanently, url)
     }
 }
 
-// MiddlewareBasicAuth
+// MiddlewareBasicAuth sets the username by AuthUserKey for the next handlers
 func MiddlewareBasicAuth(auth map[string]string) HandlerFunc {
     return func(httpCtx *Context) {
         username, password, ok := httpCtx.Input.BasicAuth()
         if ok {
             if pass, exist := auth[username]; exist && pass == password {
+                httpCtx.Set(AuthUserKey, username)
                 httpCtx.Next()
 
                 return
@@ -198,15 +210,7 @@ func (rl *RateLimiter) Limit() bool {
     return false
 }
 
-// MiddlewareRateLimit
+// MiddlewareRateLimit limits each client ip to rate requests per period, see RateLimit
 func MiddlewareRateLimit(rate uint64, period time.Duration) HandlerFunc {
-    limiter := New(rate, period)
-
-    return func(httpCtx *Context) {
-        if limiter.Limit() {
-            httpCtx.Error(http.StatusForbidden)
-        } else {
-            httpCtx.Next()
-        }
-    }
+    return NewRateLimit(int(rate), period, DefaultRateLimitSize).Middleware()
 }
@@ -0,0 +1,130 @@
+package router
+
+import (
+    "compress/gzip"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestMiddlewareETag(t *testing.T) {
+    modTime := time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)
+    router := NewRouter()
+    router.Group("/").Use(MiddlewareETag(false))
+    router.Get("/post/:id", func(httpCtx *Context) {
+        httpCtx.LastModified(modTime)
+        httpCtx.Html(http.StatusOK, []byte("<p>journey</p>"))
+    })
+    router.Get("/feed", func(httpCtx *Context) {
+        httpCtx.SetHeader("ETag", `"v1"`)
+        httpCtx.Text(http.StatusOK, []byte("feed"))
+    })
+
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/post/1", nil))
+    etag := w.Header().Get("ETag")
+    if w.Code != http.StatusOK || etag == "" || w.Body.String() != "<p>journey</p>" {
+        t.Fatalf("ServeHTTP() = %v %v %v", w.Code, etag, w.Body.String())
+    }
+
+    tests := []struct {
+        name   string
+        uri    string
+        header string
+        value  string
+        code   int
+    }{
+        {name: "1", uri: "/post/1", header: "If-None-Match", value: etag, code: http.StatusNotModified},
+        {name: "2", uri: "/post/1", header: "If-None-Match", value: `"other", W/` + etag, code: http.StatusNotModified},
+        {name: "3", uri: "/post/1", header: "If-None-Match", value: `"other"`, code: http.StatusOK},
+        {name: "4", uri: "/post/1", header: "If-Modified-Since", value: modTime.Format(http.TimeFormat), code: http.StatusNotModified},
+        {name: "5", uri: "/post/1", header: "If-Modified-Since", value: modTime.Add(-time.Hour).Format(http.TimeFormat), code: http.StatusOK},
+        {name: "6", uri: "/feed", header: "If-None-Match", value: `"v1"`, code: http.StatusNotModified},
+        {name: "7", uri: "/feed", header: "If-None-Match", value: `"v0"`, code: http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, tt.uri, nil)
+            r.Header.Set(tt.header, tt.value)
+            router.ServeHTTP(w, r)
+            if w.Code != tt.code {
+                t.Fatalf("ServeHTTP() = %v, want %v", w.Code, tt.code)
+            }
+            if tt.code == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("ETag") == "") {
+                t.Errorf("ServeHTTP() = %v %v, want empty body with ETag", w.Body.String(), w.Header().Get("ETag"))
+            }
+        })
+    }
+}
+
+func TestMiddlewareETagStream(t *testing.T) {
+    router := NewRouter()
+    router.Group("/").Use(MiddlewareCompress(gzip.DefaultCompression, 0), MiddlewareETag(false))
+    router.Get("/post", func(httpCtx *Context) {
+        httpCtx.Html(http.StatusOK, []byte("<p>journey</p>"))
+    })
+    router.Get("/events", func(httpCtx *Context) {
+        stream, err := httpCtx.EventStream()
+        if err != nil {
+            t.Fatal(err)
+        }
+        _ = stream.Send(&Event{Data: "first"})
+    })
+
+    tests := []struct {
+        name     string
+        uri      string
+        header   map[string]string
+        flushed  bool
+        etag     string
+        encoding string
+    }{
+        // the stream is committed on the first flush without the Accept header
+        {name: "1", uri: "/events", flushed: true},
+        // the Accept header does not skip the ETag
+        {name: "2", uri: "/post", header: map[string]string{"Accept": "text/event-stream"}, etag: `"`},
+        // the strong ETag of the identity body is weak for the compressed one
+        {name: "3", uri: "/post", header: map[string]string{"Accept-Encoding": "gzip"}, etag: `W/"`, encoding: "gzip"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, tt.uri, nil)
+            for key, value := range tt.header {
+                r.Header.Set(key, value)
+            }
+            router.ServeHTTP(w, r)
+
+            etag := w.Header().Get("ETag")
+            if w.Flushed != tt.flushed || (tt.etag == "") != (etag == "") || !strings.HasPrefix(etag, tt.etag) ||
+                w.Header().Get("Content-Encoding") != tt.encoding {
+                t.Errorf("ServeHTTP() = %v %v %v, want %v %v %v", w.Flushed, etag, w.Header().Get("Content-Encoding"), tt.flushed, tt.etag, tt.encoding)
+            }
+        })
+    }
+}
+
+func TestMiddlewareTimeout(t *testing.T) {
+    router := NewRouter()
+    router.Group("/").Use(MiddlewareTimeout(10 * time.Millisecond))
+    router.Get("/slow", func(httpCtx *Context) {
+        select {
+        case <-httpCtx.Context().Done():
+            httpCtx.Text(http.StatusServiceUnavailable, nil)
+        case <-time.After(time.Second):
+            httpCtx.Text(http.StatusOK, nil)
+        }
+    })
+
+    // the timeout is not skipped by the request header
+    w := httptest.NewRecorder()
+    r := httptest.NewRequest(http.MethodGet, "/slow", nil)
+    r.Header.Set("Accept", "text/event-stream")
+    router.ServeHTTP(w, r)
+    if w.Code != http.StatusServiceUnavailable {
+        t.Errorf("ServeHTTP() = %v, want %v", w.Code, http.StatusServiceUnavailable)
+    }
+}
@@ -0,0 +1,148 @@
+package router
+
+import (
+    "context"
+    "net"
+    "net/http"
+    "regexp"
+    "strings"
+)
+
+type contextKey int
+
+// paramsContextKey is the request context key of the params inherited from a parent router
+const paramsContextKey contextKey = iota
+
+// hostRule is a compiled host pattern, e.g. admin.example.com, {sub}.example.com
+type hostRule struct {
+    labels  []hostLabel
+    handler http.Handler
+}
+
+type hostLabel struct {
+    rule    string
+    key     string
+    pattern *regexp.Regexp
+}
+
+// Mount a router or any http.Handler under the prefix,
+// the prefix is stripped from the request path before it is passed to the handler
+func (r *Router) Mount(prefix string, handler http.Handler) {
+    if handler == nil {
+        return
+    }
+
+    prefix = "/" + strings.Trim(prefix, "/")
+    f := func(httpCtx *Context) {
+        req := httpCtx.Input
+        path := strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(prefix, "/"))
+        if path == "" || path[0] != '/' {
+            path = "/" + path
+        }
+
+        // pass the params of this router except the mount wildcard
+        params := make([]Param, 0, len(*httpCtx.params))
+        for _, param := range *httpCtx.params {
+            if param.Key != "*" {
+                params = append(params, param)
+            }
+        }
+
+        sub := withParams(req, params)
+        sub.URL.Path = path
+        sub.URL.RawPath = ""
+        handler.ServeHTTP(httpCtx.Output, sub)
+    }
+
+    r.tree.Insert("ANY", prefix, f)
+    r.tree.Insert("ANY", strings.TrimSuffix(prefix, "/")+"/:*", f)
+}
+
+// Host dispatches the requests whose host matches the pattern to the handler,
+// e.g. admin.example.com, {sub}.example.com, {sub:^[a-z]+$}.example.com,
+// the host params can be read by Context.GetParams in the handler if it is a router.
+// Host routing takes place before the middleware of this router
+func (r *Router) Host(pattern string, handler http.Handler) {
+    if handler == nil {
+        return
+    }
+
+    rule := &hostRule{handler: handler}
+    for _, label := range strings.Split(strings.ToLower(pattern), ".") {
+        if label == "" {
+            panic("router: host pattern compile error: " + pattern)
+        }
+
+        key, re, isWildcard := compile(label)
+        if key == "" || isWildcard {
+            panic("router: host pattern compile error: `" + label + "` in " + pattern)
+        }
+        rule.labels = append(rule.labels, hostLabel{rule: label, key: key, pattern: re})
+    }
+
+    r.mu.Lock()
+    r.hosts = append(r.hosts, rule)
+    r.mu.Unlock()
+}
+
+// matchHost returns the first host rule matching the host and its params
+func (r *Router) matchHost(host string) (*hostRule, []Param) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    if len(r.hosts) == 0 {
+        return nil, nil
+    }
+
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    labels := strings.Split(strings.ToLower(host), ".")
+
+    for _, rule := range r.hosts {
+        if len(rule.labels) != len(labels) {
+            continue
+        }
+
+        var params []Param
+        matched := true
+        for i, label := range rule.labels {
+            if label.key == label.rule {
+                // static label
+                if label.rule != labels[i] {
+                    matched = false
+                    break
+                }
+                continue
+            }
+
+            value := labels[i]
+            if label.pattern != nil {
+                result := label.pattern.FindStringSubmatch(value)
+                if len(result) == 0 {
+                    matched = false
+                    break
+                }
+                if len(result) > 1 {
+                    value = result[1]
+                }
+            }
+            params = append(params, Param{Key: label.key, Value: value})
+        }
+
+        if matched {
+            return rule, params
+        }
+    }
+
+    return nil, nil
+}
+
+// withParams returns a shallow copy of the request carrying the params for the next router
+func withParams(req *http.Request, params []Param) *http.Request {
+    sub := req.WithContext(context.WithValue(req.Context(), paramsContextKey, params))
+    u := *req.URL
+    sub.URL = &u
+
+    return sub
+}
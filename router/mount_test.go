@@ -0,0 +1,62 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestRouterMount(t *testing.T) {
+    echo := func(httpCtx *Context) {
+        sub, _ := httpCtx.GetParams("sub")
+        id, _ := httpCtx.GetParams("id")
+        httpCtx.Text(http.StatusOK, []byte(httpCtx.GetUri()+" "+sub+" "+id))
+    }
+
+    api := NewRouter()
+    api.Group("/").Use(func(httpCtx *Context) {
+        httpCtx.SetHeader("X-Api", "1")
+        httpCtx.Next()
+    })
+    api.Get("/post/:id", echo)
+
+    blog := NewRouter()
+    blog.Get("/", echo)
+
+    router := NewRouter()
+    router.Get("/post/:id", echo)
+    router.Mount("/api", api)
+    router.Host("{sub}.example.com", blog)
+
+    tests := []struct {
+        name string
+        host string
+        uri  string
+        code int
+        body string
+        api  string
+    }{
+        {name: "1", host: "example.com", uri: "/post/1", code: http.StatusOK, body: "/post/1  1"},
+        {name: "2", host: "example.com", uri: "/api/post/2", code: http.StatusOK, body: "/post/2  2", api: "1"},
+        {name: "3", host: "example.com", uri: "/api/none", code: http.StatusNotFound, api: "1"},
+        {name: "4", host: "admin.example.com:8080", uri: "/", code: http.StatusOK, body: "/ admin "},
+        {name: "5", host: "a.b.example.com", uri: "/post/3", code: http.StatusOK, body: "/post/3  3"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, tt.uri, nil)
+            r.Host = tt.host
+            router.ServeHTTP(w, r)
+            if w.Code != tt.code {
+                t.Errorf("ServeHTTP() code = %v, want %v", w.Code, tt.code)
+            }
+            if tt.body != "" && w.Body.String() != tt.body {
+                t.Errorf("ServeHTTP() body = %v, want %v", w.Body.String(), tt.body)
+            }
+            if w.Header().Get("X-Api") != tt.api {
+                t.Errorf("ServeHTTP() X-Api = %v, want %v", w.Header().Get("X-Api"), tt.api)
+            }
+        })
+    }
+}
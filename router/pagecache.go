@@ -0,0 +1,246 @@
+package router
+
+import (
+    "github.com/lanseyujie/journey/cache"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// CachedResponse is a response stored by PageCache
+type CachedResponse struct {
+    Status int
+    Header http.Header
+    Body   []byte
+    Tags   map[string]int64 // the versions of the tags when the response was stored
+    Time   time.Time
+}
+
+// PageCache stores the whole responses of GET requests in a cache adapter and serves them to GET and HEAD requests,
+// concurrent requests for the same missing page wait for the first one to render it,
+// controllers can tag the responses with the Cache-Tag header to purge them by tag later,
+// the header is removed from the responses passed through the cache
+type PageCache struct {
+    sync.Mutex
+    cache  cache.Cache
+    calls  map[string]*pageCall
+    TTL    time.Duration                 // lifetime of the responses without max-age
+    Prefix string                        // prefix of the cache keys
+    Key    func(httpCtx *Context) string // the request parts to vary the responses on, host and request uri by default
+    Bypass func(httpCtx *Context) bool   // requests neither served from nor stored to the cache, see PageCacheBypass
+}
+
+// pageCall is a rendering in flight
+type pageCall struct {
+    done     chan struct{}
+    response *CachedResponse
+}
+
+// NewPageCache
+func NewPageCache(c cache.Cache, ttl time.Duration) *PageCache {
+    return &PageCache{
+        cache:  c,
+        calls:  make(map[string]*pageCall),
+        TTL:    ttl,
+        Prefix: "page:",
+        Key: func(httpCtx *Context) string {
+            return httpCtx.Input.Host + httpCtx.Input.URL.RequestURI()
+        },
+        Bypass: PageCacheBypass,
+    }
+}
+
+// PageCacheBypass bypasses the requests with cookies or credentials, which may be personalized
+func PageCacheBypass(httpCtx *Context) bool {
+    return httpCtx.Input.Header.Get("Cookie") != "" || httpCtx.Input.Header.Get("Authorization") != ""
+}
+
+// Middleware serves the stored responses, the responses are stored unless
+// their Cache-Control contains no-store, no-cache or private, they set cookies, they are flushed or they have a CSP nonce,
+// register it after MiddlewareCompress and before MiddlewareETag
+func (pc *PageCache) Middleware() HandlerFunc {
+    return func(httpCtx *Context) {
+        method := httpCtx.Input.Method
+        if (method != http.MethodGet && method != http.MethodHead) || httpCtx.Input.Header.Get("Upgrade") != "" || pc.Bypass(httpCtx) {
+            httpCtx.Next()
+
+            return
+        }
+
+        key := pc.Prefix + pc.Key(httpCtx)
+        if response := pc.load(key); response != nil {
+            pc.serve(httpCtx, response)
+
+            return
+        }
+
+        // HEAD requests are served from the stored GET responses, their responses have no body to store
+        if method == http.MethodHead {
+            httpCtx.Next()
+
+            return
+        }
+
+        pc.Lock()
+        if call, exist := pc.calls[key]; exist {
+            pc.Unlock()
+            select {
+            case <-call.done:
+                if call.response != nil {
+                    pc.serve(httpCtx, call.response)
+
+                    return
+                }
+            case <-httpCtx.Input.Context().Done():
+                return
+            }
+            httpCtx.Next()
+
+            return
+        }
+        call := &pageCall{done: make(chan struct{})}
+        pc.calls[key] = call
+        pc.Unlock()
+
+        defer func() {
+            pc.Lock()
+            delete(pc.calls, key)
+            pc.Unlock()
+            close(call.done)
+        }()
+
+        buffered := NewBufferedResponse(httpCtx.Output)
+        hold(httpCtx, buffered, func() {
+            httpCtx.Next()
+
+            header := buffered.Header()
+            tags := header.Values("Cache-Tag")
+            header.Del("Cache-Tag")
+            header.Set("X-Cache", "MISS")
+            // the flushed streams are not buffered, and the CSP nonce must not be shared between the responses
+            if !buffered.Buffering() || buffered.Status() != http.StatusOK || httpCtx.CspNonce() != "" {
+                return
+            }
+
+            lifetime := pc.lifetime(header)
+            if lifetime <= 0 {
+                return
+            }
+
+            response := &CachedResponse{
+                Status: buffered.Status(),
+                Header: header.Clone(),
+                Body:   append([]byte(nil), buffered.Body()...),
+                Tags:   map[string]int64{"url:" + httpCtx.Input.URL.Path: pc.version("url:" + httpCtx.Input.URL.Path)},
+                Time:   time.Now(),
+            }
+            response.Header.Del("X-Cache")
+            for _, value := range tags {
+                for _, tag := range strings.Split(value, ",") {
+                    if tag = strings.TrimSpace(tag); tag != "" {
+                        response.Tags["tag:"+tag] = pc.version("tag:" + tag)
+                    }
+                }
+            }
+
+            if pc.cache.Put(key, response, lifetime) == nil {
+                call.response = response
+            }
+        })
+    }
+}
+
+// Purge the responses of the url paths, including all of their query strings
+func (pc *PageCache) Purge(paths ...string) error {
+    for _, path := range paths {
+        if err := pc.bump("url:" + path); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// PurgeTag purges the responses tagged with the tags
+func (pc *PageCache) PurgeTag(tags ...string) error {
+    for _, tag := range tags {
+        if err := pc.bump("tag:" + tag); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// load returns the stored response if none of its tags is purged
+func (pc *PageCache) load(key string) *CachedResponse {
+    var response *CachedResponse
+    switch v := pc.cache.Get(key).(type) {
+    case *CachedResponse:
+        response = v
+    case CachedResponse:
+        response = &v
+    default:
+        return nil
+    }
+
+    for tag, version := range response.Tags {
+        if pc.version(tag) != version {
+            return nil
+        }
+    }
+
+    return response
+}
+
+// serve the stored response
+func (pc *PageCache) serve(httpCtx *Context, response *CachedResponse) {
+    header := httpCtx.Output.Header()
+    for key, values := range response.Header {
+        header[key] = append([]string(nil), values...)
+    }
+    header.Set("Age", strconv.FormatInt(int64(time.Since(response.Time)/time.Second), 10))
+    header.Set("X-Cache", "HIT")
+    httpCtx.StatusCode(response.Status)
+    _, _ = httpCtx.Output.Write(response.Body)
+}
+
+// lifetime of the response by its Cache-Control, s-maxage is preferred to max-age
+func (pc *PageCache) lifetime(header http.Header) time.Duration {
+    if header.Get("Set-Cookie") != "" {
+        return 0
+    }
+
+    lifetime := pc.TTL
+    maxAge := false
+    for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+        directive = strings.ToLower(strings.TrimSpace(directive))
+        switch {
+        case directive == "no-store" || directive == "no-cache" || directive == "private":
+            return 0
+        case strings.HasPrefix(directive, "s-maxage="):
+            maxAge = true
+            seconds, _ := strconv.Atoi(directive[len("s-maxage="):])
+            lifetime = time.Duration(seconds) * time.Second
+        case strings.HasPrefix(directive, "max-age=") && !maxAge:
+            seconds, _ := strconv.Atoi(directive[len("max-age="):])
+            lifetime = time.Duration(seconds) * time.Second
+        }
+    }
+
+    return lifetime
+}
+
+// version of the tag, 0 if it has never been purged
+func (pc *PageCache) version(tag string) int64 {
+    version, _ := pc.cache.Get(pc.Prefix + tag).(int64)
+
+    return version
+}
+
+// bump the version of the tag so that the responses stored with the older version are stale
+func (pc *PageCache) bump(tag string) error {
+    return pc.cache.Put(pc.Prefix+tag, time.Now().UnixNano(), 0)
+}
@@ -0,0 +1,128 @@
+package router
+
+import (
+    "github.com/lanseyujie/journey/cache/memory"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestPageCache(t *testing.T) {
+    var renders int64
+    pc := NewPageCache(memory.NewMemory(time.Minute), time.Minute)
+    router := NewRouter()
+    router.Group("/").Use(pc.Middleware())
+    router.Get("/post/:id", func(httpCtx *Context) {
+        atomic.AddInt64(&renders, 1)
+        time.Sleep(10 * time.Millisecond)
+        httpCtx.SetHeader("Cache-Tag", "post, home")
+        httpCtx.Html(http.StatusOK, []byte("<p>journey</p>"))
+    })
+    router.Get("/private", func(httpCtx *Context) {
+        atomic.AddInt64(&renders, 1)
+        httpCtx.SetHeader("Cache-Control", "private")
+        httpCtx.Html(http.StatusOK, []byte("<p>private</p>"))
+    })
+
+    router.Group("/nonce").Use(MiddlewareSecureHeaders(&SecureHeaders{Csp: &Csp{ScriptSrc: []string{"'self'"}, Nonce: true}}))
+    router.Get("/nonce/page", func(httpCtx *Context) {
+        atomic.AddInt64(&renders, 1)
+        httpCtx.Html(http.StatusOK, []byte(`<script nonce="`+httpCtx.CspNonce()+`"></script>`))
+    })
+    router.Get("/events", func(httpCtx *Context) {
+        atomic.AddInt64(&renders, 1)
+        stream, err := httpCtx.EventStream()
+        if err != nil {
+            t.Fatal(err)
+        }
+        _ = stream.Send(&Event{Data: "first"})
+    })
+
+    router.Get("/file", func(httpCtx *Context) {
+        atomic.AddInt64(&renders, 1)
+        http.ServeContent(httpCtx.Output, httpCtx.Input, "file.txt", time.Time{}, strings.NewReader("<p>file</p>"))
+    })
+
+    request := func(method, uri, cookie string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        r := httptest.NewRequest(method, uri, nil)
+        if cookie != "" {
+            r.Header.Set("Cookie", cookie)
+        }
+        router.ServeHTTP(w, r)
+
+        return w
+    }
+    get := func(uri, cookie string) *httptest.ResponseRecorder {
+        return request(http.MethodGet, uri, cookie)
+    }
+
+    // concurrent requests render the page once
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if w := get("/post/1", ""); w.Code != http.StatusOK || w.Body.String() != "<p>journey</p>" {
+                t.Errorf("ServeHTTP() = %v %v", w.Code, w.Body.String())
+            }
+        }()
+    }
+    wg.Wait()
+
+    tests := []struct {
+        name    string
+        method  string
+        uri     string
+        cookie  string
+        purge   func()
+        renders int64
+        state   string
+        body    string
+    }{
+        {name: "1", uri: "/post/1", renders: 1, state: "HIT"},
+        {name: "2", uri: "/post/1", cookie: "session=1", renders: 2, state: ""},
+        {name: "3", uri: "/post/1?page=2", renders: 3, state: "MISS"},
+        {name: "4", uri: "/post/1", purge: func() { _ = pc.PurgeTag("post") }, renders: 4, state: "MISS"},
+        {name: "5", uri: "/post/1?page=2", purge: func() { _ = pc.Purge("/post/1") }, renders: 5, state: "MISS"},
+        {name: "6", uri: "/post/1?page=2", renders: 5, state: "HIT"},
+        {name: "7", uri: "/private", renders: 6, state: "MISS"},
+        {name: "8", uri: "/private", renders: 7, state: "MISS"},
+        {name: "9", method: http.MethodHead, uri: "/file", renders: 8, state: ""},
+        {name: "10", uri: "/file", renders: 9, state: "MISS", body: "<p>file</p>"},
+        {name: "11", method: http.MethodHead, uri: "/file", renders: 9, state: "HIT"},
+        {name: "12", uri: "/file", renders: 9, state: "HIT", body: "<p>file</p>"},
+        {name: "13", uri: "/nonce/page", renders: 10, state: "MISS"},
+        {name: "14", uri: "/nonce/page", renders: 11, state: "MISS"},
+        {name: "15", uri: "/events", renders: 12, state: "MISS"},
+        {name: "16", uri: "/events", renders: 13, state: "MISS"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if tt.purge != nil {
+                tt.purge()
+            }
+            method := tt.method
+            if method == "" {
+                method = http.MethodGet
+            }
+            w := request(method, tt.uri, tt.cookie)
+            if n := atomic.LoadInt64(&renders); n != tt.renders {
+                t.Errorf("renders = %v, want %v", n, tt.renders)
+            }
+            if state := w.Header().Get("X-Cache"); state != tt.state {
+                t.Errorf("X-Cache = %v, want %v", state, tt.state)
+            }
+            if tt.body != "" && w.Body.String() != tt.body {
+                t.Errorf("body = %v, want %v", w.Body.String(), tt.body)
+            }
+            if tt.state != "" && w.Header().Get("Cache-Tag") != "" {
+                t.Errorf("Cache-Tag = %v, want empty", w.Header().Get("Cache-Tag"))
+            }
+        })
+    }
+}
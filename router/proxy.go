@@ -0,0 +1,149 @@
+package router
+
+import (
+    "net"
+    "strconv"
+    "strings"
+)
+
+// SetTrustedProxies sets the IPs or CIDRs of the reverse proxies whose forwarding headers are trusted,
+// e.g. 127.0.0.1, 10.0.0.0/8 or ::1, the headers are ignored unless the request comes from a trusted proxy
+func (r *Router) SetTrustedProxies(proxies ...string) error {
+    networks := make([]*net.IPNet, 0, len(proxies))
+    for _, proxy := range proxies {
+        if !strings.Contains(proxy, "/") {
+            if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+                proxy += "/32"
+            } else {
+                proxy += "/128"
+            }
+        }
+
+        _, network, err := net.ParseCIDR(proxy)
+        if err != nil {
+            return err
+        }
+        networks = append(networks, network)
+    }
+
+    r.mu.Lock()
+    r.proxies = networks
+    r.mu.Unlock()
+
+    return nil
+}
+
+// trustedProxy reports whether the ip is a trusted proxy of the router
+func (r *Router) trustedProxy(ip net.IP) bool {
+    if ip == nil {
+        return false
+    }
+
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    for _, network := range r.proxies {
+        if network.Contains(ip) {
+            return true
+        }
+    }
+
+    return false
+}
+
+// parseForwarded parses the elements of the RFC 7239 Forwarded header, the parameter names are lower case
+func parseForwarded(values []string) []map[string]string {
+    var elements []map[string]string
+    for _, value := range values {
+        element := make(map[string]string)
+        for len(value) > 0 {
+            // parameter name
+            i := strings.IndexAny(value, "=;,")
+            if i < 0 || value[i] != '=' {
+                return nil
+            }
+            name := strings.ToLower(strings.TrimSpace(value[:i]))
+            value = strings.TrimLeft(value[i+1:], " \t")
+
+            // token or quoted string
+            var v string
+            if strings.HasPrefix(value, `"`) {
+                var buf strings.Builder
+                j := 1
+                for ; j < len(value) && value[j] != '"'; j++ {
+                    if value[j] == '\\' && j+1 < len(value) {
+                        j++
+                    }
+                    buf.WriteByte(value[j])
+                }
+                if j >= len(value) {
+                    return nil
+                }
+                v = buf.String()
+                value = value[j+1:]
+            } else {
+                j := strings.IndexAny(value, ";,")
+                if j < 0 {
+                    j = len(value)
+                }
+                v = strings.TrimSpace(value[:j])
+                value = value[j:]
+            }
+            element[name] = v
+
+            value = strings.TrimLeft(value, " \t")
+            if strings.HasPrefix(value, ";") {
+                value = value[1:]
+            } else if strings.HasPrefix(value, ",") {
+                elements = append(elements, element)
+                element = make(map[string]string)
+                value = value[1:]
+            }
+        }
+        if len(element) > 0 {
+            elements = append(elements, element)
+        }
+    }
+
+    return elements
+}
+
+// nodeIp returns the ip of a Forwarded node, e.g. 192.0.2.43, "[2001:db8::17]:4711", nil if it is unknown or obfuscated
+func nodeIp(node string) net.IP {
+    if strings.HasPrefix(node, "[") {
+        if i := strings.Index(node, "]"); i > 0 {
+            return net.ParseIP(node[1:i])
+        }
+
+        return nil
+    }
+
+    if host, _, err := net.SplitHostPort(node); err == nil {
+        node = host
+    }
+
+    return net.ParseIP(node)
+}
+
+// splitHost splits the host and the port, the brackets of an IPv6 literal are removed, port is 0 if absent
+func splitHost(hostport string) (host string, port int) {
+    if h, p, err := net.SplitHostPort(hostport); err == nil {
+        port, _ = strconv.Atoi(p)
+
+        return h, port
+    }
+
+    return strings.TrimSuffix(strings.TrimPrefix(hostport, "["), "]"), 0
+}
+
+// joinHost joins the host and the port, the default port of the scheme is omitted
+func joinHost(scheme, host string, port int) string {
+    if strings.Contains(host, ":") {
+        host = "[" + host + "]"
+    }
+    if port == 0 || (scheme == "http" && port == 80) || (scheme == "https" && port == 443) {
+        return host
+    }
+
+    return host + ":" + strconv.Itoa(port)
+}
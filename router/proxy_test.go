@@ -0,0 +1,115 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestTrustedProxies(t *testing.T) {
+    router := NewRouter()
+    if err := router.SetTrustedProxies("10.0.0.0/8", "::1"); err != nil {
+        t.Fatal(err)
+    }
+    if err := router.SetTrustedProxies("10.0.0.0/8", "::1", "proxy"); err == nil {
+        t.Fatal("SetTrustedProxies() = nil, want error")
+    }
+
+    tests := []struct {
+        name   string
+        remote string
+        host   string
+        header map[string]string
+        ip     string
+        scheme string
+        domain string
+        port   int
+    }{
+        {name: "1", remote: "192.0.2.1:1234", host: "example.com", ip: "192.0.2.1", scheme: "http", domain: "example.com", port: 80},
+        // untrusted peer, the headers are ignored
+        {name: "2", remote: "192.0.2.1:1234", host: "example.com:8080",
+            header: map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Forwarded-Proto": "https"},
+            ip:     "192.0.2.1", scheme: "http", domain: "example.com", port: 8080},
+        {name: "3", remote: "10.0.0.2:1234", host: "backend",
+            header: map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.7, 10.0.0.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "example.com"},
+            ip:     "198.51.100.7", scheme: "https", domain: "example.com", port: 443},
+        {name: "4", remote: "[::1]:1234", host: "[2001:db8::1]:8443",
+            header: map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.1`},
+            ip:     "2001:db8:cafe::17", scheme: "https", domain: "2001:db8::1", port: 8443},
+        // the element sent by the client is ignored
+        {name: "5", remote: "10.0.0.2:1234", host: "backend",
+            header: map[string]string{"Forwarded": `for=unknown;host=evil.example;proto=https, for=198.51.100.7;host="example.com:8080"`},
+            ip:     "198.51.100.7", scheme: "http", domain: "example.com", port: 8080},
+        {name: "6", remote: "10.0.0.2:1234", host: "example.com",
+            header: map[string]string{"X-Real-Ip": "198.51.100.7"},
+            ip:     "198.51.100.7", scheme: "http", domain: "example.com", port: 80},
+        // the values appended by the proxy are used
+        {name: "7", remote: "10.0.0.2:1234", host: "backend",
+            header: map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.7", "X-Forwarded-Proto": "https, http", "X-Forwarded-Host": "evil.example, example.com"},
+            ip:     "198.51.100.7", scheme: "http", domain: "example.com", port: 80},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := httptest.NewRequest(http.MethodGet, "/", nil)
+            r.RemoteAddr = tt.remote
+            r.Host = tt.host
+            for key, value := range tt.header {
+                r.Header.Set(key, value)
+            }
+            ctx := NewContext()
+            ctx.router = router
+            ctx.Input = r
+
+            if ip := ctx.GetClientIp(); ip != tt.ip {
+                t.Errorf("GetClientIp() = %v, want %v", ip, tt.ip)
+            }
+            if scheme := ctx.GetScheme(); scheme != tt.scheme {
+                t.Errorf("GetScheme() = %v, want %v", scheme, tt.scheme)
+            }
+            if host := ctx.GetHost(); host != tt.domain {
+                t.Errorf("GetHost() = %v, want %v", host, tt.domain)
+            }
+            if port := ctx.GetPort(); port != tt.port {
+                t.Errorf("GetPort() = %v, want %v", port, tt.port)
+            }
+        })
+    }
+}
+
+func TestMiddlewareHttpsUpgrade(t *testing.T) {
+    router := NewRouter()
+    _ = router.SetTrustedProxies("10.0.0.0/8")
+    router.Group("/").Use(MiddlewareHttpsUpgrade(8443))
+    router.Get("/post", func(httpCtx *Context) {
+        httpCtx.Text(http.StatusOK, []byte("post"))
+    })
+
+    tests := []struct {
+        name     string
+        remote   string
+        host     string
+        proto    string
+        code     int
+        location string
+    }{
+        {name: "1", remote: "192.0.2.1:1234", host: "example.com", code: http.StatusMovedPermanently, location: "https://example.com:8443/post?id=1"},
+        {name: "2", remote: "192.0.2.1:1234", host: "[::1]:8080", code: http.StatusMovedPermanently, location: "https://[::1]:8443/post?id=1"},
+        {name: "3", remote: "10.0.0.1:1234", host: "example.com", proto: "https", code: http.StatusOK},
+        {name: "4", remote: "192.0.2.1:1234", host: "example.com", proto: "https", code: http.StatusMovedPermanently, location: "https://example.com:8443/post?id=1"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, "/post?id=1", nil)
+            r.RemoteAddr = tt.remote
+            r.Host = tt.host
+            if tt.proto != "" {
+                r.Header.Set("X-Forwarded-Proto", tt.proto)
+            }
+            router.ServeHTTP(w, r)
+            if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+                t.Errorf("ServeHTTP() = %v %v, want %v %v", w.Code, w.Header().Get("Location"), tt.code, tt.location)
+            }
+        })
+    }
+}
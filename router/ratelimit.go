@@ -0,0 +1,182 @@
+package router
+
+import (
+    "container/list"
+    "github.com/lanseyujie/journey/cache"
+    "github.com/lanseyujie/journey/jwt"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DefaultRateLimitSize is the number of keys tracked in memory by default
+const DefaultRateLimitSize = 10000
+
+// RateLimit limits the requests of each key to Limit per Window with fixed window counters,
+// the counters are kept in memory for at most size keys, the least recently used ones are evicted,
+// or in Store to share the limits between processes, which is approximate between processes
+// since the counter is read and written back without an atomic increment
+type RateLimit struct {
+    sync.Mutex
+    Limit   int
+    Window  time.Duration
+    Key     func(httpCtx *Context) string // the client ip by default, see RateLimitByIp
+    Store   cache.Cache
+    Prefix  string // prefix of the store keys
+    size    int
+    windows map[string]*list.Element
+    order   *list.List
+}
+
+// rateWindow is the counter of a key in the current window
+type rateWindow struct {
+    key   string
+    count int
+    reset time.Time
+}
+
+// NewRateLimit
+func NewRateLimit(limit int, window time.Duration, size int) *RateLimit {
+    if limit < 1 {
+        limit = 1
+    }
+    if window <= 0 {
+        window = time.Second
+    }
+    if size < 1 {
+        size = DefaultRateLimitSize
+    }
+
+    return &RateLimit{
+        Limit:   limit,
+        Window:  window,
+        Key:     RateLimitByIp,
+        Prefix:  "ratelimit:",
+        size:    size,
+        windows: make(map[string]*list.Element),
+        order:   list.New(),
+    }
+}
+
+// RateLimitByIp
+func RateLimitByIp(httpCtx *Context) string {
+    return httpCtx.GetClientIp()
+}
+
+// RateLimitByRoute limits each client on each route separately
+func RateLimitByRoute(httpCtx *Context) string {
+    return httpCtx.GetClientIp() + " " + httpCtx.Input.Method + " " + httpCtx.Input.URL.Path
+}
+
+// RateLimitByJwt limits by the subject of the token verified by JwtAuth or the bearer token verified with the secret,
+// the requests without a valid token are limited by the client ip
+func RateLimitByJwt(secret string) func(httpCtx *Context) string {
+    return func(httpCtx *Context) string {
+        if token := httpCtx.Jwt(); token != nil && token.Payload.Subject != "" {
+            return "sub:" + token.Payload.Subject
+        }
+
+        auth := httpCtx.GetAuth()
+        if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+            token := jwt.NewJwt()
+            if token.Verify(auth[7:], secret) == nil && token.Payload.Subject != "" {
+                return "sub:" + token.Payload.Subject
+            }
+        }
+
+        return "ip:" + httpCtx.GetClientIp()
+    }
+}
+
+// Allow counts a request of the key, returns whether it is allowed,
+// the remaining requests and the reset time of the current window
+func (rl *RateLimit) Allow(key string) (allowed bool, remaining int, reset time.Time) {
+    now := time.Now()
+    var count int
+    if rl.Store != nil {
+        count, reset = rl.storeIncr(key, now)
+    } else {
+        count, reset = rl.localIncr(key, now)
+    }
+
+    remaining = rl.Limit - count
+    if remaining < 0 {
+        remaining = 0
+    }
+
+    return count <= rl.Limit, remaining, reset
+}
+
+// localIncr counts in memory
+func (rl *RateLimit) localIncr(key string, now time.Time) (int, time.Time) {
+    rl.Lock()
+    defer rl.Unlock()
+
+    if element, exist := rl.windows[key]; exist {
+        w := element.Value.(*rateWindow)
+        if now.Before(w.reset) {
+            w.count++
+        } else {
+            w.count = 1
+            w.reset = now.Add(rl.Window)
+        }
+        rl.order.MoveToFront(element)
+
+        return w.count, w.reset
+    }
+
+    for rl.order.Len() >= rl.size {
+        oldest := rl.order.Back()
+        rl.order.Remove(oldest)
+        delete(rl.windows, oldest.Value.(*rateWindow).key)
+    }
+    w := &rateWindow{key: key, count: 1, reset: now.Add(rl.Window)}
+    rl.windows[key] = rl.order.PushFront(w)
+
+    return w.count, w.reset
+}
+
+// storeIncr counts in the store, the windows are aligned so that all processes share them,
+// the counting is exact within the process but approximate between processes
+func (rl *RateLimit) storeIncr(key string, now time.Time) (int, time.Time) {
+    rl.Lock()
+    defer rl.Unlock()
+
+    start := now.Truncate(rl.Window)
+    reset := start.Add(rl.Window)
+    storeKey := rl.Prefix + key + ":" + strconv.FormatInt(start.Unix(), 10)
+
+    count := 1
+    switch v := rl.Store.Get(storeKey).(type) {
+    case int:
+        count += v
+    case int64:
+        count += int(v)
+    }
+    _ = rl.Store.Put(storeKey, count, reset.Sub(now))
+
+    return count, reset
+}
+
+// Middleware sets the RateLimit-* headers and responds 429 with Retry-After if the limit is exceeded
+func (rl *RateLimit) Middleware() HandlerFunc {
+    return func(httpCtx *Context) {
+        allowed, remaining, reset := rl.Allow(rl.Key(httpCtx))
+        seconds := int64(time.Until(reset)/time.Second) + 1
+
+        header := httpCtx.Output.Header()
+        header.Set("RateLimit-Limit", strconv.Itoa(rl.Limit))
+        header.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+        header.Set("RateLimit-Reset", strconv.FormatInt(seconds, 10))
+        if !allowed {
+            header.Set("Retry-After", strconv.FormatInt(seconds, 10))
+            httpCtx.Error(http.StatusTooManyRequests)
+
+            return
+        }
+
+        httpCtx.Next()
+    }
+}
@@ -0,0 +1,97 @@
+package router
+
+import (
+    "github.com/lanseyujie/journey/cache/memory"
+    "github.com/lanseyujie/journey/jwt"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestRateLimit(t *testing.T) {
+    local := NewRateLimit(2, time.Minute, 2)
+    shared := NewRateLimit(2, time.Hour, 0)
+    shared.Store = memory.NewMemory(time.Minute)
+    shared.Key = RateLimitByJwt("secret")
+
+    token := jwt.NewJwt()
+    token.Payload.Subject = "jike"
+    signed, err := token.Sign(jwt.NewHMAC(jwt.SHA256, "secret"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name      string
+        limit     *RateLimit
+        ip        string
+        auth      string
+        code      int
+        remaining string
+    }{
+        {name: "1", limit: local, ip: "192.0.2.1", code: http.StatusOK, remaining: "1"},
+        {name: "2", limit: local, ip: "192.0.2.1", code: http.StatusOK, remaining: "0"},
+        {name: "3", limit: local, ip: "192.0.2.1", code: http.StatusTooManyRequests, remaining: "0"},
+        {name: "4", limit: local, ip: "192.0.2.2", code: http.StatusOK, remaining: "1"},
+        // 192.0.2.1 is evicted
+        {name: "5", limit: local, ip: "192.0.2.3", code: http.StatusOK, remaining: "1"},
+        {name: "6", limit: local, ip: "192.0.2.1", code: http.StatusOK, remaining: "1"},
+        {name: "7", limit: shared, ip: "192.0.2.1", auth: "Bearer " + string(signed), code: http.StatusOK, remaining: "1"},
+        {name: "8", limit: shared, ip: "192.0.2.2", auth: "Bearer " + string(signed), code: http.StatusOK, remaining: "0"},
+        {name: "9", limit: shared, ip: "192.0.2.3", auth: "Bearer " + string(signed), code: http.StatusTooManyRequests, remaining: "0"},
+        {name: "10", limit: shared, ip: "192.0.2.3", auth: "Bearer forged", code: http.StatusOK, remaining: "1"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            router := NewRouter()
+            router.Group("/").Use(tt.limit.Middleware())
+            router.Get("/post", func(httpCtx *Context) {
+                httpCtx.Text(http.StatusOK, []byte("post"))
+            })
+
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, "/post", nil)
+            r.RemoteAddr = tt.ip + ":1234"
+            if tt.auth != "" {
+                r.Header.Set("Authorization", tt.auth)
+            }
+            router.ServeHTTP(w, r)
+
+            header := w.Header()
+            if w.Code != tt.code || header.Get("RateLimit-Remaining") != tt.remaining {
+                t.Fatalf("ServeHTTP() = %v %v, want %v %v", w.Code, header.Get("RateLimit-Remaining"), tt.code, tt.remaining)
+            }
+            if header.Get("RateLimit-Limit") != "2" || header.Get("RateLimit-Reset") == "" {
+                t.Errorf("RateLimit-Limit, RateLimit-Reset = %v %v", header.Get("RateLimit-Limit"), header.Get("RateLimit-Reset"))
+            }
+            if retry := header.Get("Retry-After"); (tt.code == http.StatusTooManyRequests) != (retry != "") {
+                t.Errorf("Retry-After = %v", retry)
+            }
+        })
+    }
+}
+
+func TestRateLimitStoreConcurrent(t *testing.T) {
+    rl := NewRateLimit(10, time.Hour, 0)
+    rl.Store = memory.NewMemory(time.Minute)
+
+    var allowed int64
+    var wg sync.WaitGroup
+    for i := 0; i < 50; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if ok, _, _ := rl.Allow("ip:192.0.2.1"); ok {
+                atomic.AddInt64(&allowed, 1)
+            }
+        }()
+    }
+    wg.Wait()
+
+    if allowed != 10 {
+        t.Errorf("allowed = %v, want 10", allowed)
+    }
+}
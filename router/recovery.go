@@ -0,0 +1,158 @@
+package router
+
+import (
+    "errors"
+    "fmt"
+    "github.com/lanseyujie/journey/log"
+    "github.com/lanseyujie/journey/mailer"
+    "github.com/lanseyujie/journey/utils"
+    "html"
+    "net/http"
+    "net/http/httputil"
+)
+
+// Reporter reports the recovered panic of a request with its stack trace
+type Reporter func(httpCtx *Context, err error, stack string)
+
+// Recovery recovers from the panics of the next handlers and reports them,
+// the debug page with the stack trace is shown instead of the 500 handler if Debug is set,
+// nothing is written if the status code has been sent
+type Recovery struct {
+    Debug     bool
+    Reporters []Reporter
+}
+
+// NewRecovery
+func NewRecovery(debug bool, reporters ...Reporter) *Recovery {
+    return &Recovery{
+        Debug:     debug,
+        Reporters: reporters,
+    }
+}
+
+// MiddlewareRecovery
+func MiddlewareRecovery(debug bool, reporters ...Reporter) HandlerFunc {
+    return NewRecovery(debug, reporters...).Middleware()
+}
+
+// Middleware
+func (rc *Recovery) Middleware() HandlerFunc {
+    return func(httpCtx *Context) {
+        output := httpCtx.Output
+        defer func() {
+            if e := recover(); e != nil {
+                // skip runtime.Callers, utils.StackTrace, this function and runtime.gopanic
+                rc.handle(httpCtx, output, e, utils.StackTrace(panicError(e), 4))
+            }
+        }()
+
+        httpCtx.Next()
+    }
+}
+
+// handle reports the panic and writes the error page,
+// http.ErrAbortHandler is panicked again to abort the response silently
+func (rc *Recovery) handle(httpCtx *Context, output http.ResponseWriter, e interface{}, stack string) {
+    if e == http.ErrAbortHandler {
+        panic(e)
+    }
+
+    err := panicError(e)
+    for _, report := range rc.Reporters {
+        report(httpCtx, err, stack)
+    }
+
+    if httpCtx.response.Written() {
+        return
+    }
+
+    // the writers of the next middlewares are left unfinished
+    httpCtx.Output = output
+    if buffered, ok := output.(*Response); ok && buffered.Buffering() {
+        _ = buffered.Reset()
+    }
+    header := output.Header()
+    header.Del("Content-Length")
+    header.Del("Content-Encoding")
+
+    if rc.Debug {
+        httpCtx.Html(http.StatusInternalServerError, debugPage(httpCtx, stack))
+
+        return
+    }
+
+    httpCtx.Error(http.StatusInternalServerError)
+}
+
+// ReportLog logs the stack trace and the request header
+func ReportLog(httpCtx *Context, err error, stack string) {
+    log.Error(httpCtx.Context(), stack)
+    log.Debug(dumpRequest(httpCtx.Input))
+}
+
+// ReportMail sends the stack trace and the request header by the mailer in background
+func ReportMail(m *mailer.Mailer, from *mailer.Addressee, to ...*mailer.Addressee) Reporter {
+    return func(httpCtx *Context, err error, stack string) {
+        mail := mailer.NewMail()
+        mail.From = from
+        mail.To = to
+        mail.Subject = "panic: " + err.Error()
+        mail.Body = &mailer.Body{
+            Text: "Request Id: " + httpCtx.RequestId() + "\n\n" + stack + "\n\n" + dumpRequest(httpCtx.Input),
+        }
+
+        go func() {
+            if err := m.SendMail(mail); err != nil {
+                log.Error("router: report mail:", err)
+            }
+        }()
+    }
+}
+
+// panicError converts the recovered value to an error
+func panicError(e interface{}) error {
+    switch e := e.(type) {
+    case error:
+        return e
+    case string:
+        return errors.New(e)
+    default:
+        return errors.New(fmt.Sprint(e))
+    }
+}
+
+// dumpRequest returns the request header with the credentials redacted
+func dumpRequest(req *http.Request) string {
+    r := *req
+    r.Header = req.Header.Clone()
+    for _, key := range []string{"Authorization", "Cookie", "Proxy-Authorization"} {
+        if r.Header.Get(key) != "" {
+            r.Header.Set(key, "[REDACTED]")
+        }
+    }
+
+    dump, _ := httputil.DumpRequest(&r, false)
+
+    return string(dump)
+}
+
+// debugPage renders the stack trace and the request header
+func debugPage(httpCtx *Context, stack string) []byte {
+    return []byte(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>500 Internal Server Error</title>
+<style>body{margin:2em;font-family:sans-serif}pre{padding:1em;background:#f6f8fa;overflow:auto}</style>
+</head>
+<body>
+<h1>500 Internal Server Error</h1>
+<p>Request Id: ` + html.EscapeString(httpCtx.RequestId()) + `</p>
+<h2>Stack Trace</h2>
+<pre>` + html.EscapeString(stack) + `</pre>
+<h2>Request</h2>
+<pre>` + html.EscapeString(dumpRequest(httpCtx.Input)) + `</pre>
+</body>
+</html>
+`)
+}
@@ -0,0 +1,88 @@
+package router
+
+import (
+    "compress/gzip"
+    "github.com/lanseyujie/journey/cache/memory"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestRecovery(t *testing.T) {
+    var reported []string
+    report := func(httpCtx *Context, err error, stack string) {
+        reported = append(reported, err.Error())
+        if !strings.Contains(stack, "StackTrace:") {
+            t.Errorf("stack = %v", stack)
+        }
+    }
+
+    routers := map[bool]*Router{}
+    for _, debug := range []bool{false, true} {
+        router := NewRouter()
+        router.Group("/").Use(MiddlewareRecovery(debug, report))
+        router.Group("/etag").Use(MiddlewareETag(false))
+        router.Group("/page").Use(NewPageCache(memory.NewMemory(time.Minute), time.Minute).Middleware())
+        router.Group("/gzip").Use(MiddlewareCompress(gzip.DefaultCompression, 1024))
+        router.Get("/panic", func(httpCtx *Context) {
+            panic("<b>oops</b>")
+        })
+        router.Get("/written", func(httpCtx *Context) {
+            httpCtx.Text(http.StatusOK, []byte("partial"))
+            panic("oops")
+        })
+        for _, uri := range []string{"/etag/panic", "/page/panic", "/gzip/panic"} {
+            router.Get(uri, func(httpCtx *Context) {
+                httpCtx.Text(http.StatusOK, []byte("partial"))
+                panic("oops")
+            })
+        }
+        router.Get("/abort", func(httpCtx *Context) {
+            panic(http.ErrAbortHandler)
+        })
+        routers[debug] = router
+    }
+
+    tests := []struct {
+        name     string
+        debug    bool
+        uri      string
+        code     int
+        body     string
+        reported string
+    }{
+        {name: "1", uri: "/panic", code: http.StatusInternalServerError, body: "Internal Server Error", reported: "<b>oops</b>"},
+        {name: "2", debug: true, uri: "/panic", code: http.StatusInternalServerError, body: "&lt;b&gt;oops&lt;/b&gt;", reported: "<b>oops</b>"},
+        {name: "3", uri: "/written", code: http.StatusOK, body: "partial", reported: "oops"},
+        {name: "4", uri: "/etag/panic", code: http.StatusInternalServerError, body: "Internal Server Error", reported: "oops"},
+        {name: "5", uri: "/page/panic", code: http.StatusInternalServerError, body: "Internal Server Error", reported: "oops"},
+        {name: "6", uri: "/gzip/panic", code: http.StatusInternalServerError, body: "Internal Server Error", reported: "oops"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            reported = nil
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, tt.uri, nil)
+            r.Header.Set("Accept-Encoding", "gzip")
+            routers[tt.debug].ServeHTTP(w, r)
+            if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.body) || (tt.code != http.StatusOK && strings.Contains(w.Body.String(), "partial")) {
+                t.Errorf("ServeHTTP() = %v %q, want %v %q", w.Code, w.Body.String(), tt.code, tt.body)
+            }
+            if len(reported) != 1 || reported[0] != tt.reported {
+                t.Errorf("reported = %v, want %v", reported, tt.reported)
+            }
+        })
+    }
+
+    t.Run("abort", func(t *testing.T) {
+        reported = nil
+        defer func() {
+            if e := recover(); e != http.ErrAbortHandler || len(reported) != 0 {
+                t.Errorf("recover() = %v, reported = %v", e, reported)
+            }
+        }()
+        routers[false].ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+    })
+}
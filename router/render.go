@@ -0,0 +1,206 @@
+package router
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// jsonpCallback is the pattern of the JSONP callback name, e.g. jQuery_123, app.cb, cb[0]
+var jsonpCallback = regexp.MustCompile(`^[\w$.\[\]]+$`)
+
+// Xml response, the value implementing io.WriterTo such as rss.Rss and sitemap.Sitemap writes itself
+func (ctx *Context) Xml(code int, m interface{}) {
+    ctx.Output.Header().Set("Content-Type", "application/xml; charset=utf-8")
+
+    writer := bytes.NewBuffer([]byte{})
+    var err error
+    if wt, ok := m.(io.WriterTo); ok {
+        _, err = wt.WriteTo(writer)
+    } else {
+        writer.WriteString(xml.Header)
+        err = xml.NewEncoder(writer).Encode(m)
+    }
+    if err != nil {
+        ctx.Error(http.StatusInternalServerError)
+
+        return
+    }
+
+    ctx.StatusCode(code)
+    _, _ = ctx.Output.Write(writer.Bytes())
+}
+
+// Jsonp response, it falls back to Json if the callback name is empty or invalid
+func (ctx *Context) Jsonp(code int, callback string, m interface{}) {
+    if !jsonpCallback.MatchString(callback) {
+        ctx.Json(code, m)
+
+        return
+    }
+
+    b, err := json.Marshal(&m)
+    if err != nil {
+        ctx.Error(http.StatusInternalServerError)
+
+        return
+    }
+
+    ctx.Output.Header().Set("Cache-Control", "no-store")
+    ctx.Output.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+    ctx.Output.Header().Set("X-Content-Type-Options", "nosniff")
+    ctx.StatusCode(code)
+    _, _ = ctx.Output.Write([]byte("/**/" + callback + "("))
+    _, _ = ctx.Output.Write(b)
+    _, _ = ctx.Output.Write([]byte(");"))
+}
+
+// Render response in the format negotiated from the Accept header, json by default
+func (ctx *Context) Render(code int, data interface{}) {
+    ctx.Output.Header().Add("Vary", "Accept")
+
+    switch ctx.Negotiate("application/json", "application/xml", "text/xml", "text/plain") {
+    case "application/xml", "text/xml":
+        ctx.Xml(code, data)
+    case "text/plain":
+        switch v := data.(type) {
+        case []byte:
+            ctx.Text(code, v)
+        default:
+            ctx.Text(code, []byte(fmt.Sprint(v)))
+        }
+    default:
+        ctx.Json(code, data)
+    }
+}
+
+// Negotiate returns the offer best matching the Accept header,
+// the first offer is returned if the header is empty or nothing matches
+func (ctx *Context) Negotiate(offers ...string) string {
+    if len(offers) == 0 {
+        return ""
+    }
+
+    type accept struct {
+        mediaType string
+        q         float64
+    }
+
+    var accepts []accept
+    for _, part := range strings.Split(ctx.Input.Header.Get("Accept"), ",") {
+        mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+        if err != nil {
+            continue
+        }
+
+        q := 1.0
+        if v, exist := params["q"]; exist {
+            if q, err = strconv.ParseFloat(v, 64); err != nil {
+                continue
+            }
+        }
+        if q > 0 {
+            accepts = append(accepts, accept{mediaType: mediaType, q: q})
+        }
+    }
+
+    // the more specific media type wins when the quality is the same
+    sort.SliceStable(accepts, func(i, j int) bool {
+        if accepts[i].q != accepts[j].q {
+            return accepts[i].q > accepts[j].q
+        }
+
+        return strings.Count(accepts[i].mediaType, "*") < strings.Count(accepts[j].mediaType, "*")
+    })
+
+    for _, a := range accepts {
+        for _, offer := range offers {
+            if a.mediaType == offer || a.mediaType == "*/*" ||
+                (strings.HasSuffix(a.mediaType, "/*") && strings.HasPrefix(offer, a.mediaType[:len(a.mediaType)-1])) {
+                return offer
+            }
+        }
+    }
+
+    return offers[0]
+}
+
+// File response, Range, If-Modified-Since and If-None-Match are supported
+func (ctx *Context) File(path string) {
+    ctx.serveFile(path, "")
+}
+
+// Attachment response, the browser will download the file with the name
+func (ctx *Context) Attachment(path, name string) {
+    if name == "" {
+        name = filepath.Base(path)
+    }
+
+    ctx.serveFile(path, name)
+}
+
+// serveFile responds the file, as an attachment if the name is not empty
+func (ctx *Context) serveFile(path, name string) {
+    f, err := os.Open(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            ctx.Error(http.StatusNotFound)
+        } else if os.IsPermission(err) {
+            ctx.Error(http.StatusForbidden)
+        } else {
+            ctx.Error(http.StatusInternalServerError)
+        }
+
+        return
+    }
+    defer f.Close()
+
+    info, err := f.Stat()
+    if err != nil || info.IsDir() {
+        ctx.Error(http.StatusNotFound)
+
+        return
+    }
+
+    if name != "" {
+        ctx.Output.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+        if ctx.Output.Header().Get("Content-Disposition") == "" {
+            // non ascii file name, see RFC 6266
+            ctx.Output.Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(name))
+        }
+    }
+
+    http.ServeContent(ctx.Output, ctx.Input, info.Name(), info.ModTime(), f)
+}
+
+// Stream response, step is called repeatedly until it returns false or the client is gone,
+// the output is flushed after each step, it returns true if the client is gone
+func (ctx *Context) Stream(step func(w io.Writer) bool) bool {
+    done := ctx.Input.Context().Done()
+    flusher, _ := ctx.Output.(http.Flusher)
+    for {
+        select {
+        case <-done:
+            return true
+        default:
+            keepOpen := step(ctx.Output)
+            if flusher != nil {
+                flusher.Flush()
+            }
+            if !keepOpen {
+                return false
+            }
+        }
+    }
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "testing"
+    "time"
+)
+
+func TestContextNegotiate(t *testing.T) {
+    offers := []string{"application/json", "application/xml", "text/plain"}
+    tests := []struct {
+        name   string
+        accept string
+        want   string
+    }{
+        {name: "1", accept: "", want: "application/json"},
+        {name: "2", accept: "application/xml", want: "application/xml"},
+        {name: "3", accept: "text/html,application/xml;q=0.9,*/*;q=0.8", want: "application/xml"},
+        {name: "4", accept: "text/*;q=0.5,application/json;q=0.1", want: "text/plain"},
+        {name: "5", accept: "*/*;q=0.5,text/plain", want: "text/plain"},
+        {name: "6", accept: "image/png", want: "application/json"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := NewContext()
+            ctx.Input = httptest.NewRequest(http.MethodGet, "/", nil)
+            ctx.Input.Header.Set("Accept", tt.accept)
+            if got := ctx.Negotiate(offers...); got != tt.want {
+                t.Errorf("Negotiate() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestContextFile(t *testing.T) {
+    f, err := ioutil.TempFile("", "file")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.Remove(f.Name())
+    _, _ = f.WriteString("hello world")
+    _ = f.Close()
+    modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+    _ = os.Chtimes(f.Name(), modTime, modTime)
+
+    tests := []struct {
+        name   string
+        header string
+        value  string
+        code   int
+        body   string
+    }{
+        {name: "1", code: http.StatusOK, body: "hello world"},
+        {name: "2", header: "Range", value: "bytes=0-4", code: http.StatusPartialContent, body: "hello"},
+        {name: "3", header: "If-Modified-Since", value: modTime.UTC().Format(http.TimeFormat), code: http.StatusNotModified},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            ctx := NewContext()
+            ctx.Input = httptest.NewRequest(http.MethodGet, "/", nil)
+            ctx.Output = w
+            if tt.header != "" {
+                ctx.Input.Header.Set(tt.header, tt.value)
+            }
+
+            ctx.Attachment(f.Name(), "hello.txt")
+            if w.Code != tt.code || w.Body.String() != tt.body {
+                t.Errorf("Attachment() = %v %v, want %v %v", w.Code, w.Body.String(), tt.code, tt.body)
+            }
+            if w.Header().Get("Content-Disposition") != `attachment; filename=hello.txt` {
+                t.Errorf("Attachment() Content-Disposition = %v", w.Header().Get("Content-Disposition"))
+            }
+        })
+    }
+}
@@ -0,0 +1,225 @@
+package router
+
+import (
+    "bufio"
+    "bytes"
+    "errors"
+    "net"
+    "net/http"
+    "time"
+)
+
+var ErrResponseCommitted = errors.New("router: response already committed")
+
+// Response wraps the http.ResponseWriter to record the status, size and write time of the response,
+// a buffered response holds the status and body until Commit so that middleware can replace it
+type Response struct {
+    http.ResponseWriter
+    status    int
+    size      int64
+    written   bool
+    writeTime time.Time
+    buffer    *bytes.Buffer
+}
+
+// NewResponse returns a response writing through to w
+func NewResponse(w http.ResponseWriter) *Response {
+    r := &Response{}
+    r.reset(w)
+
+    return r
+}
+
+// NewBufferedResponse returns a response holding the status and body until Commit
+func NewBufferedResponse(w http.ResponseWriter) *Response {
+    r := NewResponse(w)
+    r.buffer = &bytes.Buffer{}
+
+    return r
+}
+
+func (r *Response) reset(w http.ResponseWriter) {
+    r.ResponseWriter = w
+    r.status = http.StatusOK
+    r.size = 0
+    r.written = false
+    r.writeTime = time.Time{}
+    r.buffer = nil
+}
+
+// WriteHeader only writes the first status code, the later ones are ignored
+func (r *Response) WriteHeader(code int) {
+    if r.written {
+        return
+    }
+
+    r.status = code
+    r.written = true
+    r.writeTime = time.Now()
+    if r.buffer == nil {
+        r.ResponseWriter.WriteHeader(code)
+    }
+}
+
+// Write
+func (r *Response) Write(b []byte) (n int, err error) {
+    if !r.written {
+        r.WriteHeader(http.StatusOK)
+    }
+
+    if r.buffer != nil {
+        n, err = r.buffer.Write(b)
+    } else {
+        n, err = r.ResponseWriter.Write(b)
+    }
+    r.size += int64(n)
+
+    return
+}
+
+// Status returns the status code written, 200 if not written yet
+func (r *Response) Status() int {
+    return r.status
+}
+
+// Size returns the number of body bytes written
+func (r *Response) Size() int64 {
+    return r.size
+}
+
+// Written reports whether the status code has been written
+func (r *Response) Written() bool {
+    return r.written
+}
+
+// WriteTime returns the time the status code was written
+func (r *Response) WriteTime() time.Time {
+    return r.writeTime
+}
+
+// Buffering reports whether the response is held until Commit
+func (r *Response) Buffering() bool {
+    return r.buffer != nil
+}
+
+// Body returns the buffered body
+func (r *Response) Body() []byte {
+    if r.buffer == nil {
+        return nil
+    }
+
+    return r.buffer.Bytes()
+}
+
+// Reset discards the buffered status and body so that the response can be replaced,
+// the headers are kept
+func (r *Response) Reset() error {
+    if r.buffer == nil {
+        return ErrResponseCommitted
+    }
+
+    r.buffer.Reset()
+    r.status = http.StatusOK
+    r.size = 0
+    r.written = false
+    r.writeTime = time.Time{}
+
+    return nil
+}
+
+// Commit writes the buffered status and body to the underlying writer,
+// the response writes through afterwards
+func (r *Response) Commit() (err error) {
+    if r.buffer == nil {
+        return nil
+    }
+
+    buffer := r.buffer
+    r.buffer = nil
+    if r.written {
+        r.ResponseWriter.WriteHeader(r.status)
+    }
+    if buffer.Len() > 0 {
+        _, err = r.ResponseWriter.Write(buffer.Bytes())
+    }
+
+    return
+}
+
+// discard the buffered response, e.g. the partial response of a panic
+func (r *Response) discard() {
+    _ = r.Reset()
+}
+
+// heldWriter holds the response until it is committed, e.g. the buffered Response
+type heldWriter interface {
+    http.ResponseWriter
+    Commit() error
+    discard()
+    Unwrap() http.ResponseWriter
+}
+
+// hold sets w as the output of the context while fn runs and commits w after fn returns,
+// the partial response of a panic is discarded instead so that Recovery can write the error page,
+// the output is restored in both cases
+func hold(httpCtx *Context, w heldWriter, fn func()) {
+    httpCtx.Output = w
+    completed := false
+    defer func() {
+        if completed {
+            _ = w.Commit()
+        } else {
+            w.discard()
+        }
+        httpCtx.Output = w.Unwrap()
+    }()
+
+    fn()
+    completed = true
+}
+
+// Flush sends the data to the client, a buffered response is committed and writes through afterwards,
+// so that the streams, e.g. server-sent events, are never held
+func (r *Response) Flush() {
+    if r.buffer != nil && r.Commit() != nil {
+        return
+    }
+
+    if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+        if !r.written {
+            r.WriteHeader(http.StatusOK)
+        }
+        flusher.Flush()
+    }
+}
+
+// Hijack lets the caller take over the connection, e.g. websocket
+func (r *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    hijacker, ok := r.ResponseWriter.(http.Hijacker)
+    if !ok {
+        return nil, nil, errors.New("router: response writer does not support hijacking")
+    }
+
+    conn, rw, err := hijacker.Hijack()
+    if err == nil {
+        r.status = http.StatusSwitchingProtocols
+        r.written = true
+        r.writeTime = time.Now()
+    }
+
+    return conn, rw, err
+}
+
+// Push initiates an HTTP/2 server push
+func (r *Response) Push(target string, opts *http.PushOptions) error {
+    if pusher, ok := r.ResponseWriter.(http.Pusher); ok {
+        return pusher.Push(target, opts)
+    }
+
+    return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying writer
+func (r *Response) Unwrap() http.ResponseWriter {
+    return r.ResponseWriter
+}
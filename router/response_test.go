@@ -0,0 +1,58 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestResponse(t *testing.T) {
+    var status int
+    var size int64
+    router := NewRouter()
+    router.Group("/").Use(func(httpCtx *Context) {
+        httpCtx.Next()
+        status = httpCtx.GetStatusCode()
+        size = httpCtx.Response().Size()
+    }, func(httpCtx *Context) {
+        // replace the response of the controller
+        buffered := httpCtx.Buffer()
+        httpCtx.Next()
+        if buffered.Status() == http.StatusNotFound {
+            _ = buffered.Reset()
+            httpCtx.Text(http.StatusGone, []byte("gone"))
+        }
+        _ = buffered.Commit()
+    })
+    router.Get("/post/:id", func(httpCtx *Context) {
+        httpCtx.StatusCode(http.StatusCreated)
+        // the status code written later is ignored
+        httpCtx.Text(http.StatusOK, []byte("created"))
+    })
+    router.Get("/deleted", func(httpCtx *Context) {
+        httpCtx.Text(http.StatusNotFound, []byte("not found"))
+    })
+
+    tests := []struct {
+        name string
+        uri  string
+        code int
+        body string
+    }{
+        {name: "1", uri: "/post/1", code: http.StatusCreated, body: "created"},
+        {name: "2", uri: "/deleted", code: http.StatusGone, body: "gone"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, tt.uri, nil)
+            router.ServeHTTP(w, r)
+            if w.Code != tt.code || w.Body.String() != tt.body {
+                t.Errorf("ServeHTTP() = %v %v, want %v %v", w.Code, w.Body.String(), tt.code, tt.body)
+            }
+            if status != tt.code || size != int64(len(tt.body)) {
+                t.Errorf("Response() = %v %v, want %v %v", status, size, tt.code, len(tt.body))
+            }
+        })
+    }
+}
@@ -1,24 +1,42 @@
 package router
 
 import (
+    "net"
     "net/http"
+    "strings"
     "sync"
 )
 
 // Router
 type Router struct {
-    tree *Tree
-    pool sync.Pool
+    tree  *Tree
+    pool  sync.Pool
+    cache *matchCache
+    names map[string]*Node // route name => last node of the rule
+    hosts []*hostRule
+    // trusted reverse proxies, see SetTrustedProxies
+    proxies []*net.IPNet
+    // code => handler, group => code => handler
+    errorHandlers      map[int]HandlerFunc
+    groupErrorHandlers map[string]map[int]HandlerFunc
+    mu                 sync.RWMutex
 }
 
+// DefaultCacheSize is the default number of route matching results cached by a router
+const DefaultCacheSize = 1024
+
 // NewRouter returns a new Router
 func NewRouter() *Router {
     r := &Router{
-        tree: NewTree(),
+        tree:  NewTree(),
+        cache: newMatchCache(DefaultCacheSize),
     }
 
     r.pool.New = func() interface{} {
-        return NewContext()
+        ctx := NewContext()
+        ctx.router = r
+
+        return ctx
     }
 
     return r
@@ -32,6 +50,67 @@ func Default() *Router {
     return router
 }
 
+// SetCacheSize limits the number of cached route matching results, 0 disables the cache
+func (r *Router) SetCacheSize(size int) {
+    r.cache.resize(size)
+}
+
+// SetErrorHandler for custom error handler of the router, it takes precedence over the global one
+func (r *Router) SetErrorHandler(code int, handler HandlerFunc) {
+    r.setErrorHandler("", code, handler)
+}
+
+// setErrorHandler registers the error handler for the group, an empty group means the whole router
+func (r *Router) setErrorHandler(group string, code int, handler HandlerFunc) {
+    if handler == nil {
+        return
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if group == "" {
+        if r.errorHandlers == nil {
+            r.errorHandlers = make(map[int]HandlerFunc)
+        }
+        r.errorHandlers[code] = handler
+
+        return
+    }
+
+    if r.groupErrorHandlers == nil {
+        r.groupErrorHandlers = make(map[string]map[int]HandlerFunc)
+    }
+    if r.groupErrorHandlers[group] == nil {
+        r.groupErrorHandlers[group] = make(map[int]HandlerFunc)
+    }
+    r.groupErrorHandlers[group][code] = handler
+}
+
+// getErrorHandler returns the error handler of the longest group matching the path,
+// or the error handler of the router, nil if neither is registered
+func (r *Router) getErrorHandler(path string, code int) (handler HandlerFunc) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    length := 0
+    for group, handlers := range r.groupErrorHandlers {
+        if len(group) <= length || !strings.HasPrefix(path+"/", group) {
+            continue
+        }
+        if h, exist := handlers[code]; exist {
+            handler = h
+            length = len(group)
+        }
+    }
+
+    if handler == nil {
+        handler = r.errorHandlers[code]
+    }
+
+    return
+}
+
 // Static will quickly register a static file service route
 func (r *Router) Static(prefix, path string) {
     length := len(prefix)
@@ -86,6 +165,34 @@ func (r *Router) Delete(pattern string, f HandlerFunc) {
     }
 }
 
+// Patch
+func (r *Router) Patch(pattern string, f HandlerFunc) {
+    if f != nil {
+        r.tree.Insert(http.MethodPatch, pattern, f)
+    }
+}
+
+// Connect
+func (r *Router) Connect(pattern string, f HandlerFunc) {
+    if f != nil {
+        r.tree.Insert(http.MethodConnect, pattern, f)
+    }
+}
+
+// Trace
+func (r *Router) Trace(pattern string, f HandlerFunc) {
+    if f != nil {
+        r.tree.Insert(http.MethodTrace, pattern, f)
+    }
+}
+
+// Handle registers the controller for any method, including custom verbs, e.g. PROPFIND
+func (r *Router) Handle(method, pattern string, f HandlerFunc) {
+    if f != nil && method != "" {
+        r.tree.Insert(method, pattern, f)
+    }
+}
+
 // Any
 func (r *Router) Any(pattern string, f HandlerFunc) {
     if f != nil {
@@ -105,14 +212,43 @@ func (r *Router) PrintRoutes() {
 
 // ServeHTTP
 func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+    if rule, params := r.matchHost(req.Host); rule != nil {
+        if inherited, ok := req.Context().Value(paramsContextKey).([]Param); ok {
+            params = append(params, inherited...)
+        }
+        rule.handler.ServeHTTP(rw, withParams(req, params))
+
+        return
+    }
+
     httpCtx := r.pool.Get().(*Context)
     httpCtx.Input = req
-    httpCtx.Output = rw
+    httpCtx.response.reset(rw)
+    httpCtx.Output = httpCtx.response
     httpCtx.reset()
 
-    // TODO:// cache
-    r.tree.Match(httpCtx, req.URL.Path, req.Method)
-    httpCtx.Next()
+    // the cached result is dropped once the tree is modified
+    key := req.Method + " " + req.URL.Path
+    version := r.tree.Version()
+    if !r.cache.load(httpCtx, key, version) {
+        if r.tree.Match(httpCtx, req.URL.Path, req.Method) {
+            r.cache.store(httpCtx, key, version)
+        }
+    }
+
+    // params inherited from the parent router, e.g. host params
+    if params, ok := req.Context().Value(paramsContextKey).([]Param); ok {
+        *httpCtx.params = append(*httpCtx.params, params...)
+    }
 
-    r.pool.Put(httpCtx)
+    defer func() {
+        // net/http only removes the temporary files of the form parsed on the original request,
+        // the form may be parsed on a copy, e.g. by Context.Set or MiddlewareTimeout
+        if form := httpCtx.Input.MultipartForm; form != nil {
+            _ = form.RemoveAll()
+        }
+        r.pool.Put(httpCtx)
+    }()
+
+    httpCtx.Next()
 }
@@ -0,0 +1,49 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestRouterErrorHandler(t *testing.T) {
+    page := func(body string) HandlerFunc {
+        return func(httpCtx *Context) {
+            httpCtx.Text(http.StatusNotFound, []byte(body))
+        }
+    }
+
+    public := NewRouter()
+    public.SetErrorHandler(http.StatusNotFound, page("public"))
+    admin := NewRouter()
+    admin.SetErrorHandler(http.StatusNotFound, page("admin"))
+    admin.Group("/post").SetErrorHandler(http.StatusNotFound, page("admin post"))
+    bare := NewRouter()
+
+    tests := []struct {
+        name   string
+        router *Router
+        uri    string
+        body   string
+    }{
+        {name: "1", router: public, uri: "/none", body: "public"},
+        {name: "2", router: admin, uri: "/none", body: "admin"},
+        {name: "3", router: admin, uri: "/post/none", body: "admin post"},
+        {name: "4", router: admin, uri: "/post", body: "admin post"},
+        {name: "5", router: admin, uri: "/poster", body: "admin"},
+        {name: "6", router: bare, uri: "/none", body: http.StatusText(http.StatusNotFound)},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, tt.uri, nil)
+            tt.router.ServeHTTP(w, r)
+            if w.Code != http.StatusNotFound {
+                t.Errorf("ServeHTTP() code = %v, want %v", w.Code, http.StatusNotFound)
+            }
+            if w.Body.String() != tt.body {
+                t.Errorf("ServeHTTP() body = %v, want %v", w.Body.String(), tt.body)
+            }
+        })
+    }
+}
@@ -0,0 +1,167 @@
+package router
+
+import (
+    "github.com/lanseyujie/journey/utils"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// the keywords of the content security policy sources
+const (
+    CspSelf          = "'self'"
+    CspNone          = "'none'"
+    CspUnsafeInline  = "'unsafe-inline'"
+    CspUnsafeEval    = "'unsafe-eval'"
+    CspStrictDynamic = "'strict-dynamic'"
+    CspData          = "data:"
+    CspBlob          = "blob:"
+    CspHttps         = "https:"
+)
+
+// Csp is a content security policy, the empty directives are omitted
+type Csp struct {
+    DefaultSrc     []string
+    ScriptSrc      []string
+    StyleSrc       []string
+    ImgSrc         []string
+    ConnectSrc     []string
+    FontSrc        []string
+    ObjectSrc      []string
+    MediaSrc       []string
+    FrameSrc       []string
+    WorkerSrc      []string
+    ManifestSrc    []string
+    FrameAncestors []string
+    BaseUri        []string
+    FormAction     []string
+    // Nonce adds a per-request nonce to script-src and style-src if they are not empty,
+    // or to default-src if both are empty, see Context.CspNonce, no nonce is generated without these directives
+    Nonce                   bool
+    UpgradeInsecureRequests bool
+    ReportUri               string
+    // ReportOnly sends the policy as Content-Security-Policy-Report-Only
+    ReportOnly bool
+}
+
+// Header returns the header value of the policy with the nonce
+func (c *Csp) Header(nonce string) string {
+    var directives []string
+    add := func(name string, sources []string, withNonce bool) {
+        if withNonce && nonce != "" && len(sources) > 0 {
+            sources = append(append([]string(nil), sources...), "'nonce-"+nonce+"'")
+        }
+        if len(sources) > 0 {
+            directives = append(directives, name+" "+strings.Join(sources, " "))
+        }
+    }
+
+    add("default-src", c.DefaultSrc, c.Nonce && len(c.ScriptSrc) == 0 && len(c.StyleSrc) == 0)
+    add("script-src", c.ScriptSrc, c.Nonce)
+    add("style-src", c.StyleSrc, c.Nonce)
+    add("img-src", c.ImgSrc, false)
+    add("connect-src", c.ConnectSrc, false)
+    add("font-src", c.FontSrc, false)
+    add("object-src", c.ObjectSrc, false)
+    add("media-src", c.MediaSrc, false)
+    add("frame-src", c.FrameSrc, false)
+    add("worker-src", c.WorkerSrc, false)
+    add("manifest-src", c.ManifestSrc, false)
+    add("frame-ancestors", c.FrameAncestors, false)
+    add("base-uri", c.BaseUri, false)
+    add("form-action", c.FormAction, false)
+    if c.UpgradeInsecureRequests {
+        directives = append(directives, "upgrade-insecure-requests")
+    }
+    if c.ReportUri != "" {
+        directives = append(directives, "report-uri "+c.ReportUri)
+    }
+
+    return strings.Join(directives, "; ")
+}
+
+// hasNonce reports whether the nonce is added to the header
+func (c *Csp) hasNonce() bool {
+    return c.Nonce && (len(c.DefaultSrc) > 0 || len(c.ScriptSrc) > 0 || len(c.StyleSrc) > 0)
+}
+
+// SecureHeaders are the security headers of the responses, the empty ones are not sent
+type SecureHeaders struct {
+    // HSTS is only sent to the https requests, see Context.GetScheme
+    HstsMaxAge            time.Duration
+    HstsIncludeSubdomains bool
+    HstsPreload           bool
+    ContentTypeNosniff    bool
+    FrameOptions          string // DENY or SAMEORIGIN
+    ReferrerPolicy        string
+    PermissionsPolicy     string // e.g. camera=(), geolocation=()
+    Csp                   *Csp
+}
+
+// DefaultSecureHeaders returns the recommended security headers without a content security policy
+func DefaultSecureHeaders() *SecureHeaders {
+    return &SecureHeaders{
+        HstsMaxAge:            365 * 24 * time.Hour,
+        HstsIncludeSubdomains: true,
+        ContentTypeNosniff:    true,
+        FrameOptions:          "SAMEORIGIN",
+        ReferrerPolicy:        "strict-origin-when-cross-origin",
+        PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+    }
+}
+
+// CspNonce returns the content security policy nonce of the request to tag the inline scripts and styles,
+// empty if the policy has no nonce
+func (ctx *Context) CspNonce() string {
+    return ctx.cspNonce
+}
+
+// MiddlewareSecureHeaders sets the security headers, DefaultSecureHeaders if sh is nil
+func MiddlewareSecureHeaders(sh *SecureHeaders) HandlerFunc {
+    if sh == nil {
+        sh = DefaultSecureHeaders()
+    }
+
+    hsts := ""
+    if sh.HstsMaxAge > 0 {
+        hsts = "max-age=" + strconv.FormatInt(int64(sh.HstsMaxAge/time.Second), 10)
+        if sh.HstsIncludeSubdomains {
+            hsts += "; includeSubDomains"
+        }
+        if sh.HstsPreload {
+            hsts += "; preload"
+        }
+    }
+
+    return func(httpCtx *Context) {
+        header := httpCtx.Output.Header()
+        if hsts != "" && httpCtx.GetScheme() == "https" {
+            header.Set("Strict-Transport-Security", hsts)
+        }
+        if sh.ContentTypeNosniff {
+            header.Set("X-Content-Type-Options", "nosniff")
+        }
+        if sh.FrameOptions != "" {
+            header.Set("X-Frame-Options", sh.FrameOptions)
+        }
+        if sh.ReferrerPolicy != "" {
+            header.Set("Referrer-Policy", sh.ReferrerPolicy)
+        }
+        if sh.PermissionsPolicy != "" {
+            header.Set("Permissions-Policy", sh.PermissionsPolicy)
+        }
+
+        if sh.Csp != nil {
+            if sh.Csp.hasNonce() {
+                httpCtx.cspNonce = string(utils.RandomBytes(24))
+            }
+            name := "Content-Security-Policy"
+            if sh.Csp.ReportOnly {
+                name += "-Report-Only"
+            }
+            header.Set(name, sh.Csp.Header(httpCtx.cspNonce))
+        }
+
+        httpCtx.Next()
+    }
+}
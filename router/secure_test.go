@@ -0,0 +1,84 @@
+package router
+
+import (
+    "crypto/tls"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestMiddlewareSecureHeaders(t *testing.T) {
+    sh := DefaultSecureHeaders()
+    sh.Csp = &Csp{
+        DefaultSrc: []string{CspSelf},
+        ScriptSrc:  []string{CspSelf, CspStrictDynamic},
+        ImgSrc:     []string{CspSelf, CspData},
+        ObjectSrc:  []string{CspNone},
+        Nonce:      true,
+    }
+    router := NewRouter()
+    router.Group("/").Use(MiddlewareSecureHeaders(sh))
+    router.Get("/post", func(httpCtx *Context) {
+        httpCtx.Html(http.StatusOK, []byte(`<script nonce="`+httpCtx.CspNonce()+`"></script>`))
+    })
+
+    nonces := make(map[string]bool)
+    for _, https := range []bool{false, true, true} {
+        w := httptest.NewRecorder()
+        r := httptest.NewRequest(http.MethodGet, "/post", nil)
+        if https {
+            r.TLS = &tls.ConnectionState{}
+        }
+        router.ServeHTTP(w, r)
+
+        header := w.Header()
+        if hsts := header.Get("Strict-Transport-Security"); (hsts == "max-age=31536000; includeSubDomains") != https {
+            t.Errorf("Strict-Transport-Security = %v", hsts)
+        }
+        if header.Get("X-Content-Type-Options") != "nosniff" || header.Get("X-Frame-Options") != "SAMEORIGIN" ||
+            header.Get("Referrer-Policy") == "" || header.Get("Permissions-Policy") == "" {
+            t.Errorf("header = %v", header)
+        }
+
+        nonce := strings.TrimSuffix(strings.TrimPrefix(w.Body.String(), `<script nonce="`), `"></script>`)
+        want := "default-src 'self'; script-src 'self' 'strict-dynamic' 'nonce-" + nonce + "'; img-src 'self' data:; object-src 'none'"
+        if csp := header.Get("Content-Security-Policy"); nonce == "" || csp != want {
+            t.Errorf("Content-Security-Policy = %v, want %v", csp, want)
+        }
+        if nonces[nonce] {
+            t.Errorf("CspNonce() = %v, reused", nonce)
+        }
+        nonces[nonce] = true
+    }
+}
+
+func TestCspNonce(t *testing.T) {
+    tests := []struct {
+        name string
+        csp  *Csp
+        want string
+    }{
+        {name: "1", csp: &Csp{DefaultSrc: []string{CspSelf}, Nonce: true}, want: "default-src 'self' 'nonce-%s'"},
+        {name: "2", csp: &Csp{DefaultSrc: []string{CspSelf}, StyleSrc: []string{CspSelf}, Nonce: true}, want: "default-src 'self'; style-src 'self' 'nonce-%s'"},
+        {name: "3", csp: &Csp{ImgSrc: []string{CspSelf}, Nonce: true}, want: "img-src 'self'"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            router := NewRouter()
+            router.Group("/").Use(MiddlewareSecureHeaders(&SecureHeaders{Csp: tt.csp}))
+            router.Get("/post", func(httpCtx *Context) {
+                httpCtx.Text(http.StatusOK, []byte(httpCtx.CspNonce()))
+            })
+            w := httptest.NewRecorder()
+            router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/post", nil))
+
+            // the nonce is only exposed if the header contains it
+            nonce := w.Body.String()
+            want := strings.Replace(tt.want, "%s", nonce, 1)
+            if csp := w.Header().Get("Content-Security-Policy"); csp != want || strings.Contains(tt.want, "%s") != (nonce != "") {
+                t.Errorf("Content-Security-Policy = %v %v, want %v", csp, nonce, want)
+            }
+        })
+    }
+}
@@ -0,0 +1,450 @@
+package router
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/gob"
+    "errors"
+    "github.com/lanseyujie/journey/cache"
+    "github.com/lanseyujie/journey/utils"
+    "io"
+    "net"
+    "net/http"
+    "strings"
+    "time"
+)
+
+var ErrSessionCookie = errors.New("router: invalid session cookie")
+
+// sessionData is the stored part of a session,
+// the custom types of the values must be registered by gob.Register for the file cache and the cookie mode
+type sessionData struct {
+    Values   map[string]interface{}
+    Flashes  []string
+    Created  time.Time
+    Accessed time.Time
+}
+
+// clone returns a copy of the data, the stored data is shared by the concurrent requests of the session
+func (data *sessionData) clone() *sessionData {
+    values := make(map[string]interface{}, len(data.Values))
+    for key, value := range data.Values {
+        values[key] = value
+    }
+
+    return &sessionData{
+        Values:   values,
+        Flashes:  append([]string(nil), data.Flashes...),
+        Created:  data.Created,
+        Accessed: data.Accessed,
+    }
+}
+
+// Session is the session of a request, see Context.Session
+type Session struct {
+    id      string
+    data    *sessionData
+    fresh   bool // not loaded from the cookie
+    changed bool
+    rotated string // the id before rotation
+}
+
+// Id returns the session id, empty in the cookie-only mode
+func (s *Session) Id() string {
+    return s.id
+}
+
+// Get
+func (s *Session) Get(key string) interface{} {
+    return s.data.Values[key]
+}
+
+// Set
+func (s *Session) Set(key string, value interface{}) {
+    s.data.Values[key] = value
+    s.changed = true
+}
+
+// Delete
+func (s *Session) Delete(key string) {
+    delete(s.data.Values, key)
+    s.changed = true
+}
+
+// Flash adds a message shown once by the next request, see Flashes
+func (s *Session) Flash(message string) {
+    s.data.Flashes = append(s.data.Flashes, message)
+    s.changed = true
+}
+
+// Flashes returns and removes the flash messages, e.g. {{range .Session.Flashes}}
+func (s *Session) Flashes() []string {
+    flashes := s.data.Flashes
+    if len(flashes) > 0 {
+        s.data.Flashes = nil
+        s.changed = true
+    }
+
+    return flashes
+}
+
+// Rotate the session id keeping the values, call it when the privilege changes, e.g. login,
+// the cookie-only sessions have no id to rotate
+func (s *Session) Rotate() {
+    if s.id == "" {
+        return
+    }
+    if s.rotated == "" {
+        s.rotated = s.id
+    }
+    s.id = newSessionId()
+    s.changed = true
+}
+
+// Destroy the values and rotate the session id, the cookie is removed unless new values are set, e.g. logout
+func (s *Session) Destroy() {
+    s.data.Values = make(map[string]interface{})
+    s.data.Flashes = nil
+    s.data.Created = time.Now()
+    s.Rotate()
+    s.changed = true
+}
+
+// empty reports whether the session has nothing to store
+func (s *Session) empty() bool {
+    return len(s.data.Values) == 0 && len(s.data.Flashes) == 0
+}
+
+// newSessionId
+func newSessionId() string {
+    return string(utils.RandomBytes(32))
+}
+
+// SessionManager loads and saves the session of each request in a cache adapter,
+// or in the cookie itself if the store is nil, the cookie is signed and encrypted if Encrypt is set,
+// the sessions idle longer than IdleTimeout or older than AbsoluteTimeout are discarded
+type SessionManager struct {
+    store           cache.Cache
+    secret          []byte
+    Encrypt         bool // encrypt the cookie in the cookie-only mode
+    CookieName      string
+    Path            string
+    Domain          string
+    Secure          bool
+    SameSite        http.SameSite
+    Prefix          string // prefix of the store keys
+    IdleTimeout     time.Duration
+    AbsoluteTimeout time.Duration
+}
+
+// NewSessionManager returns a session manager storing the sessions in the store,
+// or in the cookie signed by the secret if the store is nil
+func NewSessionManager(store cache.Cache, secret string) *SessionManager {
+    return &SessionManager{
+        store:           store,
+        secret:          []byte(secret),
+        CookieName:      "session",
+        Path:            "/",
+        SameSite:        http.SameSiteLaxMode,
+        Prefix:          "session:",
+        IdleTimeout:     30 * time.Minute,
+        AbsoluteTimeout: 7 * 24 * time.Hour,
+    }
+}
+
+// Session returns the session of the request, nil if the session middleware is not used
+func (ctx *Context) Session() *Session {
+    return ctx.session
+}
+
+// Middleware loads the session before the controller and saves it before the response is written,
+// no cookie is sent until something is stored in the session
+func (m *SessionManager) Middleware() HandlerFunc {
+    return func(httpCtx *Context) {
+        session := m.load(httpCtx)
+        httpCtx.session = session
+
+        sw := &sessionWriter{ResponseWriter: httpCtx.Output}
+        sw.save = func() {
+            m.save(sw.ResponseWriter, session)
+        }
+        httpCtx.Output = sw
+        defer func() {
+            httpCtx.Output = sw.ResponseWriter
+            if !sw.saved {
+                sw.saved = true
+                m.save(sw.ResponseWriter, session)
+            } else if m.store != nil && session.changed && !session.empty() {
+                // changed after the response was written, the cookie is already sent
+                _ = m.put(session)
+            }
+        }()
+
+        httpCtx.Next()
+    }
+}
+
+// load the session of the cookie, a new one if it is absent, invalid or expired
+func (m *SessionManager) load(httpCtx *Context) *Session {
+    now := time.Now()
+    if cookie, err := httpCtx.Input.Cookie(m.CookieName); err == nil {
+        if session, err := m.decode(cookie.Value); err == nil {
+            data := session.data
+            if (m.IdleTimeout <= 0 || now.Sub(data.Accessed) <= m.IdleTimeout) &&
+                (m.AbsoluteTimeout <= 0 || now.Sub(data.Created) <= m.AbsoluteTimeout) {
+                data.Accessed = now
+
+                return session
+            }
+
+            if m.store != nil {
+                _ = m.store.Del(m.Prefix + session.id)
+            }
+        }
+    }
+
+    session := &Session{
+        data:  &sessionData{Values: make(map[string]interface{}), Created: now, Accessed: now},
+        fresh: true,
+    }
+    if m.store != nil {
+        session.id = newSessionId()
+    }
+
+    return session
+}
+
+// save the session and set the cookie
+func (m *SessionManager) save(w http.ResponseWriter, s *Session) {
+    if m.store != nil && s.rotated != "" {
+        _ = m.store.Del(m.Prefix + s.rotated)
+    }
+
+    if s.empty() {
+        if !s.fresh {
+            if m.store != nil {
+                _ = m.store.Del(m.Prefix + s.id)
+            }
+            http.SetCookie(w, m.cookie("", -1))
+        }
+
+        return
+    }
+
+    var value string
+    if m.store != nil {
+        if m.put(s) != nil {
+            return
+        }
+        if !s.fresh && s.rotated == "" {
+            return
+        }
+        value = s.id
+    } else {
+        var err error
+        if value, err = m.encode(s.data); err != nil {
+            return
+        }
+    }
+
+    maxAge := 0
+    if m.AbsoluteTimeout > 0 {
+        maxAge = int(time.Until(s.data.Created.Add(m.AbsoluteTimeout)) / time.Second)
+    }
+    http.SetCookie(w, m.cookie(value, maxAge))
+}
+
+// put the session to the store until it is idle or too old
+func (m *SessionManager) put(s *Session) error {
+    lifetime := m.IdleTimeout
+    if m.AbsoluteTimeout > 0 {
+        if remaining := time.Until(s.data.Created.Add(m.AbsoluteTimeout)); lifetime <= 0 || remaining < lifetime {
+            lifetime = remaining
+        }
+    }
+    s.changed = false
+
+    return m.store.Put(m.Prefix+s.id, s.data.clone(), lifetime)
+}
+
+// cookie returns the session cookie
+func (m *SessionManager) cookie(value string, maxAge int) *http.Cookie {
+    return &http.Cookie{
+        Name:     m.CookieName,
+        Value:    value,
+        Path:     m.Path,
+        Domain:   m.Domain,
+        MaxAge:   maxAge,
+        Secure:   m.Secure,
+        HttpOnly: true,
+        SameSite: m.SameSite,
+    }
+}
+
+// decode the cookie value to the session
+func (m *SessionManager) decode(value string) (*Session, error) {
+    if m.store != nil {
+        switch data := m.store.Get(m.Prefix + value).(type) {
+        case *sessionData:
+            return &Session{id: value, data: data.clone()}, nil
+        case sessionData:
+            return &Session{id: value, data: data.clone()}, nil
+        }
+
+        return nil, ErrSessionCookie
+    }
+
+    var b []byte
+    var err error
+    if m.Encrypt {
+        b, err = m.decrypt(value)
+    } else {
+        b, err = m.verify(value)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    data := &sessionData{}
+    if err = gob.NewDecoder(bytes.NewReader(b)).Decode(data); err != nil {
+        return nil, err
+    }
+    if data.Values == nil {
+        data.Values = make(map[string]interface{})
+    }
+
+    return &Session{data: data}, nil
+}
+
+// encode the session data to the cookie value
+func (m *SessionManager) encode(data *sessionData) (string, error) {
+    var buf bytes.Buffer
+    if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+        return "", err
+    }
+
+    if m.Encrypt {
+        return m.encrypt(buf.Bytes())
+    }
+
+    value := base64.RawURLEncoding.EncodeToString(buf.Bytes())
+
+    return value + "." + m.sign(value), nil
+}
+
+// sign returns the base64 encoded HMAC-SHA256 of the value
+func (m *SessionManager) sign(value string) string {
+    mac := hmac.New(sha256.New, m.secret)
+    _, _ = mac.Write([]byte(m.CookieName + "=" + value))
+
+    return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify the signed value and return the decoded data
+func (m *SessionManager) verify(value string) ([]byte, error) {
+    i := strings.LastIndexByte(value, '.')
+    if i < 0 || !hmac.Equal([]byte(value[i+1:]), []byte(m.sign(value[:i]))) {
+        return nil, ErrSessionCookie
+    }
+
+    return base64.RawURLEncoding.DecodeString(value[:i])
+}
+
+// aead returns the AES-GCM cipher keyed by the hash of the secret
+func (m *SessionManager) aead() (cipher.AEAD, error) {
+    key := sha256.Sum256(m.secret)
+    block, err := aes.NewCipher(key[:])
+    if err != nil {
+        return nil, err
+    }
+
+    return cipher.NewGCM(block)
+}
+
+// encrypt the data to the cookie value
+func (m *SessionManager) encrypt(b []byte) (string, error) {
+    aead, err := m.aead()
+    if err != nil {
+        return "", err
+    }
+
+    nonce := make([]byte, aead.NonceSize())
+    if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+        return "", err
+    }
+
+    return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, b, []byte(m.CookieName))), nil
+}
+
+// decrypt the cookie value
+func (m *SessionManager) decrypt(value string) ([]byte, error) {
+    aead, err := m.aead()
+    if err != nil {
+        return nil, err
+    }
+
+    b, err := base64.RawURLEncoding.DecodeString(value)
+    if err != nil || len(b) < aead.NonceSize() {
+        return nil, ErrSessionCookie
+    }
+
+    b, err = aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(m.CookieName))
+    if err != nil {
+        return nil, ErrSessionCookie
+    }
+
+    return b, nil
+}
+
+// sessionWriter saves the session before the status code is written
+type sessionWriter struct {
+    http.ResponseWriter
+    save  func()
+    saved bool
+}
+
+// WriteHeader
+func (sw *sessionWriter) WriteHeader(code int) {
+    if !sw.saved {
+        sw.saved = true
+        sw.save()
+    }
+
+    sw.ResponseWriter.WriteHeader(code)
+}
+
+// Write
+func (sw *sessionWriter) Write(b []byte) (int, error) {
+    if !sw.saved {
+        sw.WriteHeader(http.StatusOK)
+    }
+
+    return sw.ResponseWriter.Write(b)
+}
+
+// Flush
+func (sw *sessionWriter) Flush() {
+    if !sw.saved {
+        sw.WriteHeader(http.StatusOK)
+    }
+
+    if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+        flusher.Flush()
+    }
+}
+
+// Hijack
+func (sw *sessionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    hijacker, ok := sw.ResponseWriter.(http.Hijacker)
+    if !ok {
+        return nil, nil, errors.New("router: response writer does not support hijacking")
+    }
+
+    return hijacker.Hijack()
+}
@@ -0,0 +1,162 @@
+package router
+
+import (
+    "github.com/lanseyujie/journey/cache/memory"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestSessionManager(t *testing.T) {
+    stored := NewSessionManager(memory.NewMemory(time.Minute), "secret")
+    signed := NewSessionManager(nil, "secret")
+    encrypted := NewSessionManager(nil, "secret")
+    encrypted.Encrypt = true
+    idle := NewSessionManager(memory.NewMemory(time.Minute), "secret")
+    idle.IdleTimeout = 50 * time.Millisecond
+
+    for name, m := range map[string]*SessionManager{"stored": stored, "signed": signed, "encrypted": encrypted, "idle": idle} {
+        t.Run(name, func(t *testing.T) {
+            router := NewRouter()
+            router.Group("/").Use(m.Middleware())
+            router.Get("/login", func(httpCtx *Context) {
+                httpCtx.Session().Rotate()
+                httpCtx.Session().Set("user", "jike")
+                httpCtx.Session().Flash("welcome")
+                httpCtx.Text(http.StatusOK, nil)
+            })
+            router.Get("/user", func(httpCtx *Context) {
+                user, _ := httpCtx.Session().Get("user").(string)
+                httpCtx.Text(http.StatusOK, []byte(user+" "+strings.Join(httpCtx.Session().Flashes(), ",")))
+            })
+            router.Get("/logout", func(httpCtx *Context) {
+                httpCtx.Session().Destroy()
+                httpCtx.Text(http.StatusOK, nil)
+            })
+
+            cookie := ""
+            get := func(uri string) string {
+                w := httptest.NewRecorder()
+                r := httptest.NewRequest(http.MethodGet, uri, nil)
+                if cookie != "" {
+                    r.AddCookie(&http.Cookie{Name: "session", Value: cookie})
+                }
+                router.ServeHTTP(w, r)
+                for _, c := range w.Result().Cookies() {
+                    if c.MaxAge < 0 {
+                        cookie = ""
+                    } else {
+                        cookie = c.Value
+                    }
+                }
+
+                return w.Body.String()
+            }
+
+            // no cookie for an empty session
+            if body := get("/user"); body != " " || cookie != "" {
+                t.Fatalf("get() = %q %v", body, cookie)
+            }
+
+            get("/login")
+            if cookie == "" {
+                t.Fatal("no session cookie after login")
+            }
+            if m.IdleTimeout < time.Second {
+                time.Sleep(2 * m.IdleTimeout)
+                if body := get("/user"); body != " " {
+                    t.Fatalf("get() = %q after the idle timeout", body)
+                }
+
+                return
+            }
+
+            // the flash message is shown once
+            if body := get("/user"); body != "jike welcome" {
+                t.Fatalf("get() = %q", body)
+            }
+            if body := get("/user"); body != "jike " {
+                t.Fatalf("get() = %q", body)
+            }
+
+            // the old id is invalid after rotation
+            old := cookie
+            get("/login")
+            if cookie == old {
+                t.Fatal("session cookie not changed by login")
+            }
+            if m.store != nil {
+                cookie, old = old, cookie
+                if body := get("/user"); body != " " {
+                    t.Fatalf("get() = %q with the rotated id", body)
+                }
+                cookie = old
+            }
+
+            // tampered cookie
+            tampered := cookie
+            cookie = "x" + tampered[1:]
+            if tampered[0] == 'x' {
+                cookie = "y" + tampered[1:]
+            }
+            if body := get("/user"); body != " " {
+                t.Fatalf("get() = %q with a tampered cookie", body)
+            }
+            cookie = tampered
+
+            get("/logout")
+            if cookie != "" {
+                t.Fatalf("session cookie = %v after logout", cookie)
+            }
+        })
+    }
+}
+
+func TestSessionManagerConcurrent(t *testing.T) {
+    m := NewSessionManager(memory.NewMemory(time.Minute), "secret")
+    router := NewRouter()
+    router.Group("/").Use(m.Middleware())
+    router.Get("/login", func(httpCtx *Context) {
+        httpCtx.Session().Set("user", "jike")
+        httpCtx.Text(http.StatusOK, nil)
+    })
+    // the requests wait for each other so that they load the session at the same time
+    var ready sync.WaitGroup
+    ready.Add(8)
+    router.Get("/visit/:id", func(httpCtx *Context) {
+        ready.Done()
+        ready.Wait()
+        id, _ := httpCtx.GetParams("id")
+        httpCtx.Session().Set("visit:"+id, true)
+        user, _ := httpCtx.Session().Get("user").(string)
+        httpCtx.Text(http.StatusOK, []byte(user))
+    })
+
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+    cookies := w.Result().Cookies()
+    if len(cookies) != 1 {
+        t.Fatalf("cookies = %v", cookies)
+    }
+
+    // the requests of a page and its ajax calls share the session
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, "/visit/"+strconv.Itoa(i), nil)
+            r.AddCookie(cookies[0])
+            router.ServeHTTP(w, r)
+            if w.Body.String() != "jike" {
+                t.Errorf("get() = %q", w.Body.String())
+            }
+        }(i)
+    }
+    wg.Wait()
+}
@@ -0,0 +1,249 @@
+package router
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+var ErrStreamUnsupported = errors.New("router: response writer does not support streaming")
+
+// Event is a server-sent event
+type Event struct {
+    Id    string
+    Event string // event type, message if empty
+    Data  string
+    Retry time.Duration // reconnection time of the client
+}
+
+// EventStream is a server-sent events response
+type EventStream struct {
+    ctx         *Context
+    flusher     http.Flusher
+    LastEventId string // the id of the last event received by the client before reconnecting
+}
+
+// EventStream starts a server-sent events response,
+// the stream stops when the request context is done, see EventStream.Done,
+// it is not limited by MiddlewareTimeout
+func (ctx *Context) EventStream() (*EventStream, error) {
+    flusher, ok := ctx.Output.(http.Flusher)
+    if !ok {
+        return nil, ErrStreamUnsupported
+    }
+
+    if ctx.untimed != nil {
+        ctx.Input = ctx.Input.WithContext(&detachedContext{Context: ctx.Input.Context(), parent: ctx.untimed})
+    }
+
+    header := ctx.Output.Header()
+    header.Set("Content-Type", "text/event-stream; charset=utf-8")
+    header.Set("Cache-Control", "no-cache")
+    header.Set("Connection", "keep-alive")
+    // disable the response buffering of nginx
+    header.Set("X-Accel-Buffering", "no")
+    ctx.StatusCode(http.StatusOK)
+    flusher.Flush()
+
+    return &EventStream{
+        ctx:         ctx,
+        flusher:     flusher,
+        LastEventId: ctx.Input.Header.Get("Last-Event-ID"),
+    }, nil
+}
+
+// Send the event and flush it to the client
+func (es *EventStream) Send(e *Event) error {
+    var buf strings.Builder
+    if e.Id != "" {
+        buf.WriteString("id: " + singleLine(e.Id) + "\n")
+    }
+    if e.Event != "" {
+        buf.WriteString("event: " + singleLine(e.Event) + "\n")
+    }
+    if e.Retry > 0 {
+        buf.WriteString("retry: " + strconv.FormatInt(int64(e.Retry/time.Millisecond), 10) + "\n")
+    }
+    for _, line := range strings.Split(strings.ReplaceAll(e.Data, "\r\n", "\n"), "\n") {
+        buf.WriteString("data: " + line + "\n")
+    }
+    buf.WriteString("\n")
+
+    return es.write(buf.String())
+}
+
+// Comment sends a comment line, it is ignored by the client and keeps the connection alive
+func (es *EventStream) Comment(text string) error {
+    return es.write(": " + singleLine(text) + "\n\n")
+}
+
+// Done returns a channel closed when the client is gone or the request is cancelled
+func (es *EventStream) Done() <-chan struct{} {
+    return es.ctx.Input.Context().Done()
+}
+
+// write and flush
+func (es *EventStream) write(s string) error {
+    select {
+    case <-es.Done():
+        return es.ctx.Input.Context().Err()
+    default:
+    }
+
+    if _, err := es.ctx.Output.Write([]byte(s)); err != nil {
+        return err
+    }
+    es.flusher.Flush()
+
+    return nil
+}
+
+// singleLine removes the line breaks of the field
+func singleLine(s string) string {
+    return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// Broker fans the published events out to the subscribed event streams,
+// the recent events are kept so that a reconnecting client can resume from Last-Event-ID
+type Broker struct {
+    sync.Mutex
+    clients   map[chan *Event]struct{}
+    history   []*Event
+    size      int
+    seq       uint64
+    KeepAlive time.Duration // interval of the keep-alive comments, disabled if 0
+}
+
+// NewBroker returns a broker keeping the last size events for resumption
+func NewBroker(size int) *Broker {
+    return &Broker{
+        clients:   make(map[chan *Event]struct{}),
+        size:      size,
+        KeepAlive: 15 * time.Second,
+    }
+}
+
+// Publish the event to all subscribers, an id is assigned if it is empty,
+// subscribers too slow to receive the event are disconnected and can resume later
+func (b *Broker) Publish(e *Event) {
+    b.Lock()
+    defer b.Unlock()
+
+    b.seq++
+    if e.Id == "" {
+        e.Id = strconv.FormatUint(b.seq, 10)
+    }
+
+    if b.size > 0 {
+        if len(b.history) >= b.size {
+            b.history = b.history[1:]
+        }
+        b.history = append(b.history, e)
+    }
+
+    for ch := range b.clients {
+        select {
+        case ch <- e:
+        default:
+            delete(b.clients, ch)
+            close(ch)
+        }
+    }
+}
+
+// Subscribe returns the channel of new events and the events published after lastEventId,
+// cancel must be called to unsubscribe
+func (b *Broker) Subscribe(lastEventId string) (ch <-chan *Event, missed []*Event, cancel func()) {
+    b.Lock()
+    defer b.Unlock()
+
+    if lastEventId != "" {
+        for i, e := range b.history {
+            if e.Id == lastEventId {
+                missed = append(missed, b.history[i+1:]...)
+                break
+            }
+        }
+    }
+
+    c := make(chan *Event, 16)
+    b.clients[c] = struct{}{}
+    cancel = func() {
+        b.Lock()
+        if _, exist := b.clients[c]; exist {
+            delete(b.clients, c)
+            close(c)
+        }
+        b.Unlock()
+    }
+
+    return c, missed, cancel
+}
+
+// Handler returns a controller streaming the events of the broker to the client
+func (b *Broker) Handler() HandlerFunc {
+    return func(httpCtx *Context) {
+        stream, err := httpCtx.EventStream()
+        if err != nil {
+            httpCtx.Error(http.StatusInternalServerError)
+
+            return
+        }
+
+        ch, missed, cancel := b.Subscribe(stream.LastEventId)
+        defer cancel()
+
+        for _, e := range missed {
+            if stream.Send(e) != nil {
+                return
+            }
+        }
+
+        var tick <-chan time.Time
+        if b.KeepAlive > 0 {
+            ticker := time.NewTicker(b.KeepAlive)
+            defer ticker.Stop()
+            tick = ticker.C
+        }
+
+        for {
+            select {
+            case <-stream.Done():
+                return
+            case e, ok := <-ch:
+                if !ok || stream.Send(e) != nil {
+                    return
+                }
+            case <-tick:
+                if stream.Comment("ping") != nil {
+                    return
+                }
+            }
+        }
+    }
+}
+
+// detachedContext keeps the values of the context but the deadline and cancellation of the parent
+type detachedContext struct {
+    context.Context
+    parent context.Context
+}
+
+// Deadline
+func (dc *detachedContext) Deadline() (time.Time, bool) {
+    return dc.parent.Deadline()
+}
+
+// Done
+func (dc *detachedContext) Done() <-chan struct{} {
+    return dc.parent.Done()
+}
+
+// Err
+func (dc *detachedContext) Err() error {
+    return dc.parent.Err()
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestBroker(t *testing.T) {
+    broker := NewBroker(8)
+    broker.Publish(&Event{Data: "first"})
+    broker.Publish(&Event{Event: "comment", Data: "second\nline"})
+
+    router := NewRouter()
+    router.Group("/").Use(MiddlewareTimeout(time.Millisecond))
+    router.Get("/events", broker.Handler())
+
+    ctx, cancel := context.WithCancel(context.Background())
+    w := httptest.NewRecorder()
+    r := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+    r.Header.Set("Last-Event-ID", "1")
+
+    done := make(chan struct{})
+    go func() {
+        router.ServeHTTP(w, r)
+        close(done)
+    }()
+
+    // longer than the timeout of the middleware
+    time.Sleep(50 * time.Millisecond)
+    broker.Publish(&Event{Id: "x", Data: "third", Retry: time.Second})
+    time.Sleep(50 * time.Millisecond)
+    cancel()
+    <-done
+
+    want := "id: 2\nevent: comment\ndata: second\ndata: line\n\n" + "id: x\nretry: 1000\ndata: third\n\n"
+    if w.Body.String() != want {
+        t.Fatalf("body = %q, want %q", w.Body.String(), want)
+    }
+    if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+        t.Fatalf("Content-Type = %v", w.Header().Get("Content-Type"))
+    }
+}
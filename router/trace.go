@@ -0,0 +1,117 @@
+package router
+
+import (
+    "encoding/hex"
+    "github.com/lanseyujie/journey/log"
+    "github.com/lanseyujie/journey/utils"
+    "strings"
+)
+
+// the headers of the request id and the W3C trace context
+const (
+    HeaderRequestId   = "X-Request-Id"
+    HeaderTraceparent = "traceparent"
+    HeaderTracestate  = "tracestate"
+)
+
+// Trace returns the trace of the request, nil if the trace middleware is not used
+func (ctx *Context) Trace() *log.Trace {
+    return ctx.trace
+}
+
+// RequestId returns the request id, empty if the trace middleware is not used
+func (ctx *Context) RequestId() string {
+    if ctx.trace == nil {
+        return ""
+    }
+
+    return ctx.trace.RequestId
+}
+
+// MiddlewareTrace accepts the request id and the trace context of the request or generates them,
+// stores them on the context and the request context for log.Http and log.Error, and echoes them in the response,
+// the span id of the request is new and the traceparent of the caller becomes its parent
+func MiddlewareTrace() HandlerFunc {
+    return func(httpCtx *Context) {
+        trace := &log.Trace{
+            RequestId: httpCtx.GetHeader(HeaderRequestId),
+            SpanId:    randomHex(8),
+        }
+        if !validRequestId(trace.RequestId) {
+            trace.RequestId = utils.NewUuidV4().String()
+        }
+
+        var ok bool
+        if trace.TraceId, trace.ParentId, trace.Flags, ok = parseTraceparent(httpCtx.GetHeader(HeaderTraceparent)); ok {
+            // the tracestate is dropped with an invalid traceparent
+            trace.State = strings.Join(httpCtx.Input.Header.Values(HeaderTracestate), ",")
+        } else {
+            trace.TraceId, trace.Flags = randomHex(16), "00"
+        }
+
+        httpCtx.trace = trace
+        httpCtx.Input = httpCtx.Input.WithContext(log.WithTrace(httpCtx.Input.Context(), trace))
+
+        header := httpCtx.Output.Header()
+        header.Set(HeaderRequestId, trace.RequestId)
+        header.Set(HeaderTraceparent, trace.Traceparent())
+        if trace.State != "" {
+            header.Set(HeaderTracestate, trace.State)
+        }
+
+        httpCtx.Next()
+    }
+}
+
+// validRequestId reports whether the request id is short and printable
+func validRequestId(id string) bool {
+    if id == "" || len(id) > 128 {
+        return false
+    }
+    for i := 0; i < len(id); i++ {
+        if id[i] < 0x21 || id[i] > 0x7e {
+            return false
+        }
+    }
+
+    return true
+}
+
+// parseTraceparent returns the trace id, the parent id and the flags of the traceparent header,
+// the fields after the flags of the future versions are ignored
+func parseTraceparent(value string) (traceId, parentId, flags string, ok bool) {
+    value = strings.TrimSpace(value)
+    if len(value) < 55 || (len(value) > 55 && value[55] != '-') ||
+        value[2] != '-' || value[35] != '-' || value[52] != '-' {
+        return
+    }
+
+    version := value[:2]
+    if !isLowerHex(version) || version == "ff" || (version == "00" && len(value) != 55) {
+        return
+    }
+
+    traceId, parentId, flags = value[3:35], value[36:52], value[53:55]
+    if !isLowerHex(traceId) || !isLowerHex(parentId) || !isLowerHex(flags) ||
+        traceId == strings.Repeat("0", 32) || parentId == strings.Repeat("0", 16) {
+        return "", "", "", false
+    }
+
+    return traceId, parentId, flags, true
+}
+
+// isLowerHex
+func isLowerHex(s string) bool {
+    for i := 0; i < len(s); i++ {
+        if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+            return false
+        }
+    }
+
+    return true
+}
+
+// randomHex returns n random bytes in lowercase hex
+func randomHex(n int) string {
+    return hex.EncodeToString(utils.RandomBytes(n, utils.Ascii...))
+}
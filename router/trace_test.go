@@ -0,0 +1,81 @@
+package router
+
+import (
+    "bytes"
+    "github.com/lanseyujie/journey/log"
+    "github.com/lanseyujie/journey/log/console"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestMiddlewareTrace(t *testing.T) {
+    var buf bytes.Buffer
+    log.SetDefaultLog(log.Adapter(&buf))
+    defer log.SetDefaultLog(log.Adapter(console.NewConsole()))
+
+    router := NewRouter()
+    router.Group("/").Use(MiddlewareLogger(), MiddlewareTrace())
+    router.Get("/post", func(httpCtx *Context) {
+        trace := log.TraceFrom(httpCtx.Context())
+        if trace == nil || trace != httpCtx.Trace() {
+            t.Errorf("TraceFrom() = %v, want %v", trace, httpCtx.Trace())
+        }
+        httpCtx.Text(http.StatusOK, []byte(httpCtx.RequestId()))
+    })
+
+    parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+    tests := []struct {
+        name        string
+        requestId   string
+        traceparent string
+        tracestate  string
+        keepId      bool
+        keepTrace   bool
+    }{
+        {name: "1"},
+        {name: "2", requestId: "abc-123", traceparent: parent, tracestate: "rojo=00f067aa0ba902b7", keepId: true, keepTrace: true},
+        {name: "3", traceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", keepTrace: true},
+        {name: "4", requestId: "bad id", traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", tracestate: "rojo=1"},
+        {name: "5", traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+        {name: "6", traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+        {name: "7", traceparent: parent + "-extra"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            buf.Reset()
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, "/post", nil)
+            if tt.requestId != "" {
+                r.Header.Set(HeaderRequestId, tt.requestId)
+            }
+            if tt.traceparent != "" {
+                r.Header.Set(HeaderTraceparent, tt.traceparent)
+            }
+            if tt.tracestate != "" {
+                r.Header.Set(HeaderTracestate, tt.tracestate)
+            }
+            router.ServeHTTP(w, r)
+
+            id := w.Header().Get(HeaderRequestId)
+            if (tt.keepId && id != tt.requestId) || (!tt.keepId && len(id) != 36) || w.Body.String() != id {
+                t.Errorf("request id = %v %v, want %v", id, w.Body.String(), tt.requestId)
+            }
+
+            traceparent := w.Header().Get(HeaderTraceparent)
+            traceId, parentId, _, ok := parseTraceparent(traceparent)
+            if !ok || (tt.keepTrace && traceId != tt.traceparent[3:35]) || (!tt.keepTrace && traceId == "4bf92f3577b34da6a3ce929d0e0e4736") ||
+                parentId == "00f067aa0ba902b7" {
+                t.Errorf("traceparent = %v, from %v", traceparent, tt.traceparent)
+            }
+            if tracestate := w.Header().Get(HeaderTracestate); (tt.keepTrace && tracestate != tt.tracestate) || (!tt.keepTrace && tracestate != "") {
+                t.Errorf("tracestate = %v, want %v", tracestate, tt.tracestate)
+            }
+
+            if !strings.Contains(buf.String(), "[HTTP]") || !strings.Contains(buf.String(), "request_id="+id+" trace_id="+traceId) {
+                t.Errorf("log = %q", buf.String())
+            }
+        })
+    }
+}
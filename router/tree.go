@@ -6,14 +6,17 @@ import (
     "reflect"
     "regexp"
     "runtime"
+    "sort"
     "strconv"
     "strings"
+    "sync/atomic"
 )
 
 // Tree is a prefix tree that routing rules with the same namespace
 // will share the same prefix node, a bit like Trie
 type Tree struct {
-    root *Node
+    root    *Node
+    version uint64 // increased on every insert to invalidate the match cache
 }
 
 // NewTree returns a new prefix tree
@@ -44,6 +47,7 @@ type Node struct {
     pattern    *regexp.Regexp
     parent     *Node
     children   map[string]*Node
+    dynamic    []*Node // regexp, param and wildcard children ordered by priority
     handlers   map[string]HandlersChain
     middleware HandlersChain
 }
@@ -66,6 +70,13 @@ func NewNode(rule string, depth int) *Node {
 
 // Insert a routing rule into the tree
 func (t *Tree) Insert(method, fullRule string, handler HandlerFunc, middleware ...HandlerFunc) {
+    t.insert(method, fullRule, handler, middleware...)
+}
+
+// insert a routing rule into the tree and returns the last node of the rule
+func (t *Tree) insert(method, fullRule string, handler HandlerFunc, middleware ...HandlerFunc) *Node {
+    defer atomic.AddUint64(&t.version, 1)
+
     currentNode := t.root
     // always start with /
     if fullRule == "" || fullRule[0] != '/' {
@@ -101,16 +112,13 @@ func (t *Tree) Insert(method, fullRule string, handler HandlerFunc, middleware .
                 node.pattern = pattern
                 node.parent = currentNode
                 currentNode.children[rule] = node
+                if key != rule {
+                    currentNode.addDynamic(node)
+                }
             }
 
             currentNode = node
 
-            // save the passed middleware to the handlers
-            // handlers will be used directly when the route matching hits
-            if handler != nil && len(currentNode.middleware) > 0 {
-                handlers = append(handlers, currentNode.middleware...)
-            }
-
             // do not register nodes after wildcard nodes
             if isWildcard {
                 break
@@ -129,104 +137,57 @@ func (t *Tree) Insert(method, fullRule string, handler HandlerFunc, middleware .
 
     // register the controller method at the last node
     if handler != nil {
-        handlers = append(handlers, currentNode.middleware...)
+        // save the middleware from root to the last node to the handlers
+        // handlers will be used directly when the route matching hits
+        var nodes []*Node
+        for node := currentNode; node != nil; node = node.parent {
+            nodes = append(nodes, node)
+        }
+        for i := len(nodes) - 1; i >= 0; i-- {
+            handlers = append(handlers, nodes[i].middleware...)
+        }
         handlers = append(handlers, handler)
         currentNode.handlers[strings.ToUpper(method)] = handlers
     }
+
+    return currentNode
 }
 
-// Match the request uri in the tree to get the target node
-func (t *Tree) Match(ctx *Context, requestUri, method string) {
+// Match the request uri in the tree to get the target node,
+// found reports whether a handler is registered for the method
+func (t *Tree) Match(ctx *Context, requestUri, method string) (found bool) {
     currentNode := t.root
     if currentNode.fullRule != requestUri {
-        length := len(requestUri)
-        start := 1
-        for i := start; i <= length; i++ {
-            if i < length && requestUri[i] != '/' {
-                continue
-            }
-            name := requestUri[start:i]
-            if name == "" {
-                continue
-            }
-
-            // match a node that meets the rules in children nodes
-            node, found := currentNode.children[name]
-            if !found {
-                for _, childNode := range currentNode.children {
-                    if childNode.key != childNode.rule {
-                        if childNode.isWildcard {
-                            // for wildcard
-                            if childNode.key == "*" || childNode.key == name+"*" {
-                                found = true
-                                j := len(*ctx.params)
-                                *ctx.params = (*ctx.params)[:j+1]
-                                (*ctx.params)[j] = Param{
-                                    Key:   childNode.key,
-                                    Value: requestUri[start:],
-                                }
-                                node = childNode
-
-                                break
-                            }
-                        } else {
-                            // for regexp rule
-                            if childNode.pattern != nil {
-                                result := childNode.pattern.FindStringSubmatch(name)
-                                if len(result) == 2 {
-                                    found = true
-                                    j := len(*ctx.params)
-                                    *ctx.params = (*ctx.params)[:j+1]
-                                    (*ctx.params)[j] = Param{
-                                        Key:   childNode.key,
-                                        Value: result[1],
-                                    }
-                                    node = childNode
-
-                                    break
-                                }
-                            }
-                        }
-                    }
-                }
-
-                // node not found
-                if !found {
-                    currentNode = nil
-
-                    break
-                }
-            }
-
-            // node found
-            currentNode = node
-            if currentNode.isWildcard {
-                // do not match nodes after wildcard nodes
-                break
-            }
-
-            start = i + 1
-        }
+        currentNode = currentNode.match(ctx, requestUri, 1)
     }
 
     if currentNode != nil {
-        var exist bool
-        ctx.handlers, exist = currentNode.handlers[method]
+        ctx.handlers = currentNode.handlers[method]
+        found = true
         if ctx.handlers == nil {
             // call the GET handler if the HEAD handler does not exist
             if method == http.MethodHead {
-                ctx.handlers, exist = currentNode.handlers[http.MethodGet]
+                ctx.handlers = currentNode.handlers[http.MethodGet]
             }
 
             if ctx.handlers == nil {
                 // default handler
-                ctx.handlers, exist = currentNode.handlers["ANY"]
+                ctx.handlers = currentNode.handlers["ANY"]
                 if ctx.handlers == nil {
+                    found = false
                     ctx.handlers = append(ctx.handlers, t.root.middleware...)
-                    if exist {
-                        ctx.handlers = append(ctx.handlers, GetErrorHandler(http.StatusNotImplemented))
+                    allow := currentNode.allow()
+                    if method == http.MethodOptions {
+                        // respond to OPTIONS automatically if it is not registered
+                        ctx.handlers = append(ctx.handlers, func(httpCtx *Context) {
+                            httpCtx.SetHeader("Allow", allow)
+                            httpCtx.StatusCode(http.StatusNoContent)
+                        })
                     } else {
-                        ctx.handlers = append(ctx.handlers, GetErrorHandler(http.StatusMethodNotAllowed))
+                        ctx.handlers = append(ctx.handlers, func(httpCtx *Context) {
+                            httpCtx.SetHeader("Allow", allow)
+                            httpCtx.Error(http.StatusMethodNotAllowed)
+                        })
                     }
                 }
             }
@@ -234,12 +195,141 @@ func (t *Tree) Match(ctx *Context, requestUri, method string) {
     } else {
         // not found
         ctx.handlers = append(ctx.handlers, t.root.middleware...)
-        ctx.handlers = append(ctx.handlers, GetErrorHandler(http.StatusNotFound))
+        ctx.handlers = append(ctx.handlers, errorFunc(http.StatusNotFound))
     }
 
     return
 }
 
+// Version returns the number of insertions since the tree was created
+func (t *Tree) Version() uint64 {
+    return atomic.LoadUint64(&t.version)
+}
+
+// allow returns the methods registered on the node for the Allow header
+func (n *Node) allow() string {
+    methods := make([]string, 0, len(n.handlers)+2)
+    for method := range n.handlers {
+        methods = append(methods, method)
+    }
+
+    // HEAD is served by the GET handler and OPTIONS is answered automatically
+    if _, exist := n.handlers[http.MethodGet]; exist {
+        if _, exist = n.handlers[http.MethodHead]; !exist {
+            methods = append(methods, http.MethodHead)
+        }
+    }
+    if _, exist := n.handlers[http.MethodOptions]; !exist {
+        methods = append(methods, http.MethodOptions)
+    }
+    sort.Strings(methods)
+
+    return strings.Join(methods, ", ")
+}
+
+// priority returns the matching order of the node among its dynamic siblings,
+// the lower the value the earlier the node is tried
+func (n *Node) priority() int {
+    switch {
+    case n.isWildcard:
+        // e.g. :*, :static*
+        return 2
+    case n.pattern == nil:
+        // e.g. :str
+        return 1
+    default:
+        // e.g. :id, :name, :uid(^([\d]+)$)
+        return 0
+    }
+}
+
+// addDynamic keeps the dynamic children ordered by priority,
+// nodes with the same priority are tried in the order of registration
+func (n *Node) addDynamic(child *Node) {
+    index := len(n.dynamic)
+    for i, node := range n.dynamic {
+        if node.priority() > child.priority() {
+            index = i
+            break
+        }
+    }
+
+    n.dynamic = append(n.dynamic, nil)
+    copy(n.dynamic[index+1:], n.dynamic[index:])
+    n.dynamic[index] = child
+}
+
+// match the rest of the request uri from start in the children of the node,
+// static children take precedence over regexp, param and wildcard children,
+// and the next sibling is tried when a deeper segment does not match
+func (n *Node) match(ctx *Context, requestUri string, start int) *Node {
+    length := len(requestUri)
+    // skip empty segments, e.g. //
+    for start < length && requestUri[start] == '/' {
+        start++
+    }
+
+    // the whole request uri has been consumed
+    if start >= length {
+        if len(n.handlers) > 0 {
+            return n
+        }
+
+        return nil
+    }
+
+    end := strings.IndexByte(requestUri[start:], '/')
+    if end < 0 {
+        end = length
+    } else {
+        end += start
+    }
+    name := requestUri[start:end]
+
+    // static rule
+    if node, found := n.children[name]; found && node.key == node.rule {
+        if target := node.match(ctx, requestUri, end); target != nil {
+            return target
+        }
+    }
+
+    mark := len(*ctx.params)
+    for _, node := range n.dynamic {
+        if node.isWildcard {
+            // for wildcard, do not match nodes after wildcard nodes
+            if (node.key == "*" || node.key == name+"*") && len(node.handlers) > 0 {
+                ctx.addParam(node.key, requestUri[start:])
+
+                return node
+            }
+
+            continue
+        }
+
+        value := name
+        if node.pattern != nil {
+            // for regexp rule
+            result := node.pattern.FindStringSubmatch(name)
+            if len(result) == 0 {
+                continue
+            }
+            if len(result) > 1 {
+                value = result[1]
+            }
+        }
+
+        ctx.addParam(node.key, value)
+        if target := node.match(ctx, requestUri, end); target != nil {
+            return target
+        }
+
+        // backtrack
+        *ctx.params = (*ctx.params)[:mark]
+    }
+
+    return nil
+}
+
 // PrintRoutes print the controller and middleware for each routing rule
 func (t *Tree) PrintRoutes(node *Node) {
     if node == nil {
@@ -0,0 +1,120 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+// newMatchTree returns a tree whose nodes mix static, regexp, param and wildcard children
+func newMatchTree() *Tree {
+    tree := NewTree()
+    handler := func(httpCtx *Context) {}
+    rules := []string{
+        "/post/:*",
+        "/post/:str",
+        "/post/:name",
+        "/post/:id/edit",
+        "/post/latest",
+        "/user/:uid(^u([\\d]+)$)",
+        "/user/:name",
+        "/user/:name/:*",
+        "/file/{*}",
+        "/file/{hash:^([a-f0-9]{8})$}",
+    }
+    for _, rule := range rules {
+        tree.Insert(http.MethodGet, rule, handler)
+    }
+
+    return tree
+}
+
+func TestTreeMatch(t *testing.T) {
+    tests := []struct {
+        name   string
+        uri    string
+        rule   string
+        params []Param
+    }{
+        {name: "static", uri: "/post/latest", rule: "/post/latest"},
+        {name: "backtrack to name", uri: "/post/123", rule: "/post/:name", params: []Param{{"name", "123"}}},
+        {name: "regexp before param", uri: "/post/123/edit", rule: "/post/:id/edit", params: []Param{{"id", "123"}}},
+        {name: "name before param", uri: "/post/hello-world", rule: "/post/:name", params: []Param{{"name", "hello-world"}}},
+        {name: "param before wildcard", uri: "/post/hello.html", rule: "/post/:str", params: []Param{{"str", "hello.html"}}},
+        {name: "wildcard", uri: "/post/hello/world", rule: "/post/:*", params: []Param{{"*", "hello/world"}}},
+        {name: "backtrack to wildcard", uri: "/post/abc/edit", rule: "/post/:*", params: []Param{{"*", "abc/edit"}}},
+        {name: "regexp group", uri: "/user/u42", rule: "/user/:uid(^u([\\d]+)$)", params: []Param{{"uid", "42"}}},
+        {name: "regexp fallback", uri: "/user/jike", rule: "/user/:name", params: []Param{{"name", "jike"}}},
+        {name: "deeper wildcard", uri: "/user/u42/a/b", rule: "/user/:name/:*", params: []Param{{"name", "u42"}, {"*", "a/b"}}},
+        {name: "brace regexp", uri: "/file/deadbeef", rule: "/file/{hash:^([a-f0-9]{8})$}", params: []Param{{"hash", "deadbeef"}}},
+        {name: "brace wildcard", uri: "/file/a/b.txt", rule: "/file/{*}", params: []Param{{"*", "a/b.txt"}}},
+        {name: "not found", uri: "/user", rule: ""},
+    }
+
+    // map iteration order differs between runs and between maps,
+    // so build the tree several times and match each uri repeatedly
+    for i := 0; i < 20; i++ {
+        tree := newMatchTree()
+        ctx := NewContext()
+        for _, tt := range tests {
+            for j := 0; j < 50; j++ {
+                ctx.reset()
+                node := tree.root
+                if node.fullRule != tt.uri {
+                    node = node.match(ctx, tt.uri, 1)
+                }
+
+                rule := ""
+                if node != nil {
+                    rule = node.fullRule
+                }
+                if rule != tt.rule {
+                    t.Fatalf("%s: match(%s) = %v, want %v", tt.name, tt.uri, rule, tt.rule)
+                }
+
+                if len(*ctx.params) != len(tt.params) {
+                    t.Fatalf("%s: params = %v, want %v", tt.name, *ctx.params, tt.params)
+                }
+                for k, param := range *ctx.params {
+                    if param != tt.params[k] {
+                        t.Fatalf("%s: params = %v, want %v", tt.name, *ctx.params, tt.params)
+                    }
+                }
+            }
+        }
+    }
+}
+
+func TestTreeMatchAllow(t *testing.T) {
+    router := NewRouter()
+    handler := func(httpCtx *Context) {}
+    router.Get("/post/:id", handler)
+    router.Patch("/post/:id", handler)
+    router.Handle("PROPFIND", "/post/:id", handler)
+
+    tests := []struct {
+        name   string
+        method string
+        code   int
+        allow  string
+    }{
+        {name: "1", method: http.MethodGet, code: http.StatusOK},
+        {name: "2", method: http.MethodPatch, code: http.StatusOK},
+        {name: "3", method: "PROPFIND", code: http.StatusOK},
+        {name: "4", method: http.MethodPost, code: http.StatusMethodNotAllowed, allow: "GET, HEAD, OPTIONS, PATCH, PROPFIND"},
+        {name: "5", method: http.MethodOptions, code: http.StatusNoContent, allow: "GET, HEAD, OPTIONS, PATCH, PROPFIND"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(tt.method, "/post/1", nil)
+            router.ServeHTTP(w, r)
+            if w.Code != tt.code {
+                t.Errorf("ServeHTTP() code = %v, want %v", w.Code, tt.code)
+            }
+            if allow := w.Header().Get("Allow"); allow != tt.allow {
+                t.Errorf("ServeHTTP() Allow = %v, want %v", allow, tt.allow)
+            }
+        })
+    }
+}
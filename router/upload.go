@@ -0,0 +1,214 @@
+package router
+
+import (
+    "errors"
+    "github.com/lanseyujie/journey/utils"
+    "io"
+    "mime"
+    "mime/multipart"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// DefaultMaxBodySize is the default max size of a multipart request body
+const DefaultMaxBodySize = 32 << 20
+
+var (
+    ErrBodyTooLarge   = errors.New("router: request body too large")
+    ErrFileTooLarge   = errors.New("router: upload file too large")
+    ErrFileType       = errors.New("router: upload file type not allowed")
+    ErrFileNotFound   = errors.New("router: upload file not found")
+    ErrNotMultipart   = errors.New("router: request is not multipart")
+    ErrFileNameExists = errors.New("router: upload file name exists")
+)
+
+// Upload limits the multipart file uploads
+type Upload struct {
+    MaxBodySize int64    // max size of the request body, DefaultMaxBodySize if 0
+    MaxFileSize int64    // max size of each file, unlimited if 0
+    AllowTypes  []string // allowed sniffed MIME types, e.g. image/png, image/*, all types are allowed if empty
+}
+
+// UploadFile is an uploaded file with the MIME type sniffed from its content
+type UploadFile struct {
+    *multipart.FileHeader
+    ContentType string
+}
+
+// GetFile returns the first uploaded file of the form field
+func (ctx *Context) GetFile(key string, upload *Upload) (*UploadFile, error) {
+    files, err := ctx.GetFiles(key, upload)
+    if err != nil {
+        return nil, err
+    }
+
+    return files[0], nil
+}
+
+// GetFiles returns the uploaded files of the form field checked by the upload limits,
+// respond 413 for ErrBodyTooLarge and ErrFileTooLarge
+func (ctx *Context) GetFiles(key string, upload *Upload) (files []*UploadFile, err error) {
+    if upload == nil {
+        upload = &Upload{}
+    }
+
+    if ctx.Input.MultipartForm == nil {
+        mediaType, _, _ := mime.ParseMediaType(ctx.Input.Header.Get("Content-Type"))
+        if mediaType != "multipart/form-data" {
+            return nil, ErrNotMultipart
+        }
+
+        maxBodySize := upload.MaxBodySize
+        if maxBodySize <= 0 {
+            maxBodySize = DefaultMaxBodySize
+        }
+        if ctx.Input.ContentLength > maxBodySize {
+            return nil, ErrBodyTooLarge
+        }
+
+        ctx.Input.Body = http.MaxBytesReader(ctx.Output, ctx.Input.Body, maxBodySize)
+        if err = ctx.Input.ParseMultipartForm(DefaultMaxMemory); err != nil {
+            if bodyTooLarge(err) {
+                err = ErrBodyTooLarge
+            }
+
+            return nil, err
+        }
+    }
+
+    headers := ctx.Input.MultipartForm.File[key]
+    if len(headers) == 0 {
+        return nil, ErrFileNotFound
+    }
+
+    for _, header := range headers {
+        if upload.MaxFileSize > 0 && header.Size > upload.MaxFileSize {
+            return nil, ErrFileTooLarge
+        }
+
+        var contentType string
+        contentType, err = sniff(header)
+        if err != nil {
+            return nil, err
+        }
+
+        if !allowType(contentType, upload.AllowTypes) {
+            return nil, ErrFileType
+        }
+
+        files = append(files, &UploadFile{FileHeader: header, ContentType: contentType})
+    }
+
+    return
+}
+
+// SaveFile saves the uploaded file to the directory with a uuid name,
+// the extension is kept if it matches the sniffed type, it returns the path of the saved file
+func (ctx *Context) SaveFile(file *UploadFile, dir string) (path string, err error) {
+    if err = os.MkdirAll(dir, 0755); err != nil {
+        return
+    }
+
+    src, err := file.Open()
+    if err != nil {
+        return
+    }
+    defer src.Close()
+
+    ext := extension(file.Filename, file.ContentType)
+    var dst *os.File
+    // retry in the unlikely case of uuid collision
+    for i := 0; i < 3; i++ {
+        path = filepath.Join(dir, utils.NewUuidV4().String()+ext)
+        dst, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+        if err == nil || !os.IsExist(err) {
+            break
+        }
+    }
+    if err != nil {
+        if os.IsExist(err) {
+            err = ErrFileNameExists
+        }
+
+        return "", err
+    }
+
+    _, err = io.Copy(dst, src)
+    if e := dst.Close(); err == nil {
+        err = e
+    }
+    if err != nil {
+        _ = os.Remove(path)
+
+        return "", err
+    }
+
+    return
+}
+
+// sniff the MIME type of the file from its first 512 bytes
+func sniff(header *multipart.FileHeader) (string, error) {
+    f, err := header.Open()
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+
+    buf := make([]byte, 512)
+    n, err := io.ReadFull(f, buf)
+    if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+        return "", err
+    }
+
+    contentType := http.DetectContentType(buf[:n])
+    if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+        contentType = mediaType
+    }
+
+    return contentType, nil
+}
+
+// allowType reports whether the MIME type matches one of the allowed types, e.g. image/*
+func allowType(contentType string, allowTypes []string) bool {
+    if len(allowTypes) == 0 {
+        return true
+    }
+
+    for _, allow := range allowTypes {
+        if allow == contentType || (strings.HasSuffix(allow, "/*") && strings.HasPrefix(contentType, allow[:len(allow)-1])) {
+            return true
+        }
+    }
+
+    return false
+}
+
+// extension returns a safe extension of the file name matching the MIME type
+func extension(name, contentType string) string {
+    ext := strings.ToLower(filepath.Ext(name))
+    for _, c := range strings.TrimPrefix(ext, ".") {
+        if !('a' <= c && c <= 'z') && !('0' <= c && c <= '9') {
+            ext = ""
+            break
+        }
+    }
+
+    if ext != "" {
+        if t, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil && t == contentType {
+            return ext
+        }
+    }
+
+    if exts, err := mime.ExtensionsByType(contentType); err == nil && len(exts) > 0 {
+        return exts[0]
+    }
+
+    return ""
+}
+
+// bodyTooLarge reports whether the error is returned by the reader of http.MaxBytesReader
+func bodyTooLarge(err error) bool {
+    return strings.Contains(err.Error(), "http: request body too large")
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+    "bytes"
+    "io/ioutil"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// png header
+var pngData = []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00")
+
+func newUploadRequest(t *testing.T, name string, data []byte) *http.Request {
+    body := &bytes.Buffer{}
+    writer := multipart.NewWriter(body)
+    part, err := writer.CreateFormFile("image", name)
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, _ = part.Write(data)
+    _ = writer.Close()
+
+    r := httptest.NewRequest(http.MethodPost, "/upload", body)
+    r.Header.Set("Content-Type", writer.FormDataContentType())
+
+    return r
+}
+
+func TestContextUpload(t *testing.T) {
+    dir, err := ioutil.TempDir("", "upload")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    tests := []struct {
+        name    string
+        file    string
+        data    []byte
+        upload  *Upload
+        chunked bool // the body size is unknown
+        wantErr error
+        ext     string
+    }{
+        {name: "1", file: "a.PNG", data: pngData, upload: &Upload{AllowTypes: []string{"image/*"}}, ext: ".png"},
+        {name: "2", file: "a.png", data: []byte("<?php echo 1;"), upload: &Upload{AllowTypes: []string{"image/*"}}, wantErr: ErrFileType},
+        {name: "3", file: "a.png", data: pngData, upload: &Upload{MaxFileSize: 8}, wantErr: ErrFileTooLarge},
+        {name: "4", file: "a.png", data: pngData, upload: &Upload{MaxBodySize: 64}, wantErr: ErrBodyTooLarge},
+        {name: "5", file: "a.png", data: pngData, upload: &Upload{MaxBodySize: 64}, chunked: true, wantErr: ErrBodyTooLarge},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            ctx := NewContext()
+            ctx.Input = newUploadRequest(t, tt.file, tt.data)
+            if tt.chunked {
+                ctx.Input.ContentLength = -1
+            }
+            ctx.Output = w
+
+            file, err := ctx.GetFile("image", tt.upload)
+            if err != tt.wantErr {
+                t.Fatalf("GetFile() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            // the error is left to the caller
+            if w.Code != http.StatusOK || w.Body.Len() != 0 {
+                t.Fatalf("GetFile() wrote %v %v", w.Code, w.Body.String())
+            }
+            if err != nil {
+                return
+            }
+
+            path, err := ctx.SaveFile(file, dir)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if filepath.Ext(path) != tt.ext || strings.Contains(path, file.Filename) {
+                t.Fatalf("SaveFile() path = %v, want ext %v", path, tt.ext)
+            }
+            if saved, _ := ioutil.ReadFile(path); !bytes.Equal(saved, tt.data) {
+                t.Fatalf("SaveFile() saved = %v, want %v", saved, tt.data)
+            }
+        })
+    }
+}
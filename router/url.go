@@ -0,0 +1,150 @@
+package router
+
+import (
+    "errors"
+    "fmt"
+    "net/url"
+    "regexp"
+    "regexp/syntax"
+    "strings"
+)
+
+// Name the routing rule so that its url can be generated by URL
+func (r *Router) Name(name, pattern string) {
+    node := r.tree.insert("ANY", pattern, nil)
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if r.names == nil {
+        r.names = make(map[string]*Node)
+    }
+    if _, exist := r.names[name]; exist {
+        panic("router: route name `" + name + "` registered repeatedly")
+    }
+    r.names[name] = node
+}
+
+// URL returns the path of the named route,
+// params fill the :id, {uid:...} and :* segments in order, the param of a capturing pattern
+// is the captured value as returned by GetParams, e.g. 42 for {uid:^u([\d]+)$}, or the whole segment
+func (r *Router) URL(name string, params ...interface{}) (string, error) {
+    r.mu.RLock()
+    node, exist := r.names[name]
+    r.mu.RUnlock()
+    if !exist {
+        return "", errors.New("router: route name `" + name + "` not found")
+    }
+
+    // collect the nodes from root to the named node
+    var nodes []*Node
+    for n := node; n != nil && n.parent != nil; n = n.parent {
+        nodes = append(nodes, n)
+    }
+
+    var path strings.Builder
+    index := 0
+    for i := len(nodes) - 1; i >= 0; i-- {
+        n := nodes[i]
+        path.WriteByte('/')
+
+        // static rule
+        if n.key == n.rule {
+            path.WriteString(n.rule)
+            continue
+        }
+
+        if index >= len(params) {
+            return "", errors.New("router: missing param `" + n.key + "` for route `" + name + "`")
+        }
+        value := fmt.Sprint(params[index])
+        index++
+
+        if n.isWildcard {
+            // :static* only matches paths starting with static
+            prefix := strings.TrimSuffix(n.key, "*")
+            if prefix != "" && value != prefix && !strings.HasPrefix(value, prefix+"/") {
+                return "", errors.New("router: param `" + value + "` does not match `" + n.rule + "`")
+            }
+
+            segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+            for j, segment := range segments {
+                segments[j] = url.PathEscape(segment)
+            }
+            path.WriteString(strings.Join(segments, "/"))
+            continue
+        }
+
+        if n.pattern != nil {
+            segment, ok := patternSegment(n.pattern, value)
+            if !ok {
+                return "", errors.New("router: param `" + value + "` does not match `" + n.rule + "`")
+            }
+            value = segment
+        } else if value == "" || strings.Contains(value, "/") {
+            return "", errors.New("router: param `" + value + "` does not match `" + n.rule + "`")
+        }
+        path.WriteString(url.PathEscape(value))
+    }
+
+    if index != len(params) {
+        return "", errors.New("router: too many params for route `" + name + "`")
+    }
+
+    if path.Len() == 0 {
+        return "/", nil
+    }
+
+    return path.String(), nil
+}
+
+// patternSegment returns the path segment of the param value, the segment is rebuilt
+// from the literals around the first capture group if the pattern captures the value
+func patternSegment(pattern *regexp.Regexp, value string) (string, bool) {
+    if pattern.NumSubexp() > 0 {
+        if prefix, suffix, ok := captureLiterals(pattern.String()); ok {
+            segment := prefix + value + suffix
+            if result := pattern.FindStringSubmatch(segment); len(result) > 1 && result[1] == value {
+                return segment, true
+            }
+        }
+    }
+
+    return value, pattern.MatchString(value)
+}
+
+// captureLiterals returns the literals before and after the capture group of the expression,
+// ok is false unless the expression only consists of literals, anchors and one capture group
+func captureLiterals(expr string) (prefix, suffix string, ok bool) {
+    re, err := syntax.Parse(expr, syntax.Perl)
+    if err != nil {
+        return
+    }
+
+    subs := []*syntax.Regexp{re}
+    if re.Op == syntax.OpConcat {
+        subs = re.Sub
+    }
+
+    var before, after strings.Builder
+    for _, sub := range subs {
+        switch sub.Op {
+        case syntax.OpBeginText, syntax.OpEndText, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpEmptyMatch:
+        case syntax.OpLiteral:
+            if ok {
+                after.WriteString(string(sub.Rune))
+            } else {
+                before.WriteString(string(sub.Rune))
+            }
+        case syntax.OpCapture:
+            if ok || sub.Cap != 1 {
+                return "", "", false
+            }
+            ok = true
+        default:
+            return "", "", false
+        }
+    }
+
+    return before.String(), after.String(), ok
+}
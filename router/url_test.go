@@ -0,0 +1,61 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestRouterURL(t *testing.T) {
+    router := NewRouter()
+    handler := func(httpCtx *Context) {}
+    router.Group("/").Target("/post/:id").Name("post").Get(handler)
+    router.Group("/user").Target("{uid:^u[\\d]+$}/:str").Name("user").Get(handler)
+    router.Group("/member").Target("{uid:^u([\\d]+)$}").Name("member").Get(func(httpCtx *Context) {
+        uid, _ := httpCtx.GetParams("uid")
+        url, _ := httpCtx.router.URL("member", uid)
+        httpCtx.Text(http.StatusOK, []byte(url))
+    })
+    router.Name("static", "/static/:*")
+    router.Name("home", "/")
+
+    tests := []struct {
+        name    string
+        route   string
+        params  []interface{}
+        want    string
+        wantErr bool
+    }{
+        {name: "1", route: "post", params: []interface{}{123}, want: "/post/123"},
+        {name: "2", route: "post", params: []interface{}{"abc"}, wantErr: true},
+        {name: "3", route: "post", params: nil, wantErr: true},
+        {name: "4", route: "post", params: []interface{}{1, 2}, wantErr: true},
+        {name: "5", route: "user", params: []interface{}{"u42", "hello world"}, want: "/user/u42/hello%20world"},
+        {name: "6", route: "user", params: []interface{}{"42", "hello"}, wantErr: true},
+        {name: "7", route: "static", params: []interface{}{"css/style.css"}, want: "/static/css/style.css"},
+        {name: "8", route: "home", params: nil, want: "/"},
+        {name: "9", route: "none", params: nil, wantErr: true},
+        {name: "10", route: "member", params: []interface{}{42}, want: "/member/u42"},
+        {name: "11", route: "member", params: []interface{}{"u42"}, want: "/member/u42"},
+        {name: "12", route: "member", params: []interface{}{"x"}, wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := router.URL(tt.route, tt.params...)
+            if (err != nil) != tt.wantErr {
+                t.Errorf("URL() error = %v, wantErr %v", err, tt.wantErr)
+                return
+            }
+            if got != tt.want {
+                t.Errorf("URL() got = %v, want %v", got, tt.want)
+            }
+        })
+    }
+
+    // the captured value round-trips through GetParams and URL
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/member/u42", nil))
+    if w.Body.String() != "/member/u42" {
+        t.Errorf("URL() = %v, want /member/u42", w.Body.String())
+    }
+}
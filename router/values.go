@@ -0,0 +1,95 @@
+package router
+
+import (
+    "context"
+    "time"
+)
+
+// AuthUserKey is the key of the authenticated user set by MiddlewareBasicAuth and JwtAuth
+const AuthUserKey = "user"
+
+// valuesKey is the request context key of the values set by Context.Set
+type valuesKey struct{}
+
+// Set stores a value for the request, the value is also readable from the request context by ContextValue,
+// the values are attached to the request context once on the first Set
+func (ctx *Context) Set(key string, value interface{}) {
+    if ctx.values == nil {
+        ctx.values = make(map[string]interface{})
+        if ctx.Input != nil {
+            ctx.Input = ctx.Input.WithContext(context.WithValue(ctx.Input.Context(), valuesKey{}, ctx.values))
+        }
+    }
+    ctx.values[key] = value
+}
+
+// Get returns the value of the key set by Set
+func (ctx *Context) Get(key string) (value interface{}, exist bool) {
+    value, exist = ctx.values[key]
+
+    return
+}
+
+// MustGet returns the value of the key, it panics if the key is not set
+func (ctx *Context) MustGet(key string) interface{} {
+    if value, exist := ctx.values[key]; exist {
+        return value
+    }
+
+    panic("router: key " + key + " does not exist")
+}
+
+// GetString
+func (ctx *Context) GetString(key string) (s string) {
+    s, _ = ctx.values[key].(string)
+
+    return
+}
+
+// GetInt
+func (ctx *Context) GetInt(key string) (i int) {
+    i, _ = ctx.values[key].(int)
+
+    return
+}
+
+// GetInt64
+func (ctx *Context) GetInt64(key string) (i int64) {
+    i, _ = ctx.values[key].(int64)
+
+    return
+}
+
+// GetFloat64
+func (ctx *Context) GetFloat64(key string) (f float64) {
+    f, _ = ctx.values[key].(float64)
+
+    return
+}
+
+// GetBool
+func (ctx *Context) GetBool(key string) (b bool) {
+    b, _ = ctx.values[key].(bool)
+
+    return
+}
+
+// GetTime
+func (ctx *Context) GetTime(key string) (t time.Time) {
+    t, _ = ctx.values[key].(time.Time)
+
+    return
+}
+
+// Context returns the request context carrying the values set by Set,
+// e.g. the ctx func of orm.Query, it outlives the router context
+func (ctx *Context) Context() context.Context {
+    return ctx.Input.Context()
+}
+
+// ContextValue returns the value of the key set by Context.Set from the request context
+func ContextValue(ctx context.Context, key string) interface{} {
+    values, _ := ctx.Value(valuesKey{}).(map[string]interface{})
+
+    return values[key]
+}
@@ -0,0 +1,55 @@
+package router
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestContextValues(t *testing.T) {
+    router := NewRouter()
+    router.Group("/").Use(MiddlewareBasicAuth(map[string]string{"jike": "secret"}), func(httpCtx *Context) {
+        httpCtx.Set("id", 7)
+        httpCtx.Next()
+    })
+    query := func(ctx context.Context) string {
+        user, _ := ContextValue(ctx, AuthUserKey).(string)
+
+        return user
+    }
+    router.Get("/user", func(httpCtx *Context) {
+        if _, exist := httpCtx.Get("none"); exist || httpCtx.GetInt("id") != 7 || httpCtx.GetString("id") != "" {
+            t.Errorf("Get() = %v %v", httpCtx.GetInt("id"), httpCtx.GetString("id"))
+        }
+        // the request is not copied for each value
+        input := httpCtx.Input
+        httpCtx.Set("page", 2)
+        if httpCtx.Input != input || ContextValue(httpCtx.Context(), "page") != 2 {
+            t.Errorf("Set() copied the request or ContextValue() = %v", ContextValue(httpCtx.Context(), "page"))
+        }
+        httpCtx.Text(http.StatusOK, []byte(httpCtx.MustGet(AuthUserKey).(string)+" "+query(httpCtx.Context())))
+    })
+
+    tests := []struct {
+        name string
+        user string
+        pass string
+        code int
+        body string
+    }{
+        {name: "1", user: "jike", pass: "secret", code: http.StatusOK, body: "jike jike"},
+        {name: "2", user: "jike", pass: "wrong", code: http.StatusUnauthorized},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            r := httptest.NewRequest(http.MethodGet, "/user", nil)
+            r.SetBasicAuth(tt.user, tt.pass)
+            router.ServeHTTP(w, r)
+            if w.Code != tt.code || (tt.code == http.StatusOK && w.Body.String() != tt.body) {
+                t.Errorf("ServeHTTP() = %v %q, want %v %q", w.Code, w.Body.String(), tt.code, tt.body)
+            }
+        })
+    }
+}
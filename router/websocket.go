@@ -0,0 +1,23 @@
+package router
+
+import (
+    "github.com/lanseyujie/journey/websocket"
+    "net/http"
+)
+
+// Upgrade the request to a WebSocket connection,
+// the error page is responded through the error handler if the handshake fails
+func (ctx *Context) Upgrade(opts *websocket.Options) (*websocket.Conn, error) {
+    conn, err := websocket.Upgrade(ctx.Output, ctx.Input, opts)
+    if err != nil {
+        if e, ok := err.(*websocket.HandshakeError); ok {
+            ctx.Error(e.Status)
+        }
+
+        return nil, err
+    }
+
+    ctx.code = http.StatusSwitchingProtocols
+
+    return conn, nil
+}
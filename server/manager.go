@@ -22,6 +22,7 @@ type Manager struct {
     logFile   *os.File
     pidFile   *PidFile
     service   []Service
+    hooks     []func(ctx context.Context)
     errorChan chan error
 }
 
@@ -96,6 +97,14 @@ func (m *Manager) AddService(service ...Service) *Manager {
     return m
 }
 
+// OnShutdown registers the hooks called on shutdown, e.g. websocket.Shutdown,
+// hijacked connections are not closed by http.Server.Shutdown
+func (m *Manager) OnShutdown(hook ...func(ctx context.Context)) *Manager {
+    m.hooks = append(m.hooks, hook...)
+
+    return m
+}
+
 // Master
 func (m *Manager) Master() {
     if m.pidFile == nil {
@@ -249,6 +258,9 @@ func (m *Manager) shutdown() {
             go s.Release(ctx)
         }
     }
+    for _, hook := range m.hooks {
+        go hook(ctx)
+    }
 
     select {
     case <-ctx.Done():
@@ -1,6 +1,7 @@
 package theme
 
 import (
+    "errors"
     "html/template"
     "strings"
     "time"
@@ -8,6 +9,14 @@ import (
 
 var funcMap = make(template.FuncMap)
 
+// UrlBuilder returns the url of a named route, e.g. the URL method of router.Router
+type UrlBuilder func(name string, params ...interface{}) (string, error)
+
+var urlBuilder UrlBuilder
+
+// CsrfFieldName is the form field name of the csrf token, it must match the one of the csrf middleware
+var CsrfFieldName = "_csrf"
+
 func init() {
     AddFuncMap("html", Html)
     AddFuncMap("string", String)
@@ -18,6 +27,9 @@ func init() {
     AddFuncMap("sub", Subtract)
     AddFuncMap("mul", Multiply)
     AddFuncMap("div", Divide)
+    AddFuncMap("url", Url)
+    AddFuncMap("csrffield", CsrfField)
+    AddFuncMap("cspnonce", CspNonce)
 }
 
 // AddFuncMap register a func in the template
@@ -25,6 +37,31 @@ func AddFuncMap(key string, fn interface{}) {
     funcMap[key] = fn
 }
 
+// SetUrlBuilder sets the builder used by the url template function
+func SetUrlBuilder(builder UrlBuilder) {
+    urlBuilder = builder
+}
+
+// Url returns the url of a named route, e.g. {{url "post" .Id}}
+func Url(name string, params ...interface{}) (string, error) {
+    if urlBuilder == nil {
+        return "", errors.New("theme: url builder is not set")
+    }
+
+    return urlBuilder(name, params...)
+}
+
+// CsrfField returns the hidden form field of the csrf token, e.g. {{csrffield .CsrfToken}}
+func CsrfField(token string) template.HTML {
+    return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(CsrfFieldName) +
+        `" value="` + template.HTMLEscapeString(token) + `">`)
+}
+
+// CspNonce returns the nonce attribute of the inline scripts and styles, e.g. <script {{cspnonce .CspNonce}}>
+func CspNonce(nonce string) template.HTMLAttr {
+    return template.HTMLAttr(`nonce="` + template.HTMLEscapeString(nonce) + `"`)
+}
+
 // Html
 func Html(str string) template.HTML {
     return template.HTML(str)
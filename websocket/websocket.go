@@ -0,0 +1,629 @@
+package websocket
+
+import (
+    "bufio"
+    "bytes"
+    "compress/flate"
+    "context"
+    "crypto/sha1"
+    "encoding/base64"
+    "encoding/binary"
+    "errors"
+    "io"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "unicode/utf8"
+)
+
+// message types, see RFC 6455 section 11.8
+const (
+    ContinuationMessage = 0
+    TextMessage         = 1
+    BinaryMessage       = 2
+    CloseMessage        = 8
+    PingMessage         = 9
+    PongMessage         = 10
+)
+
+// close codes, see RFC 6455 section 7.4.1
+const (
+    CloseNormal          = 1000
+    CloseGoingAway       = 1001
+    CloseProtocolError   = 1002
+    CloseUnsupportedData = 1003
+    CloseNoStatus        = 1005
+    CloseAbnormal        = 1006
+    CloseInvalidPayload  = 1007
+    ClosePolicyViolation = 1008
+    CloseMessageTooBig   = 1009
+    CloseMandatoryExt    = 1010
+    CloseInternalError   = 1011
+)
+
+// DefaultReadLimit is the default max size of a received message
+const DefaultReadLimit = 16 << 20
+
+const acceptGuid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+    ErrClosed       = errors.New("websocket: connection closed")
+    ErrReadLimit    = errors.New("websocket: message too big")
+    ErrControlFrame = errors.New("websocket: invalid control frame")
+)
+
+// tail of a deflate block flushed with sync flush, see RFC 7692 section 7.2.1
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// HandshakeError is returned by Upgrade if the request is not a valid handshake
+type HandshakeError struct {
+    Status  int
+    Message string
+}
+
+// Error
+func (e *HandshakeError) Error() string {
+    return "websocket: " + e.Message
+}
+
+// CloseError is returned by ReadMessage when a close frame is received
+type CloseError struct {
+    Code int
+    Text string
+}
+
+// Error
+func (e *CloseError) Error() string {
+    return "websocket: close " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// Options of the handshake
+type Options struct {
+    Subprotocols []string                   // supported subprotocols in order of preference
+    CheckOrigin  func(r *http.Request) bool // same origin is required if nil
+    Compression  bool                       // negotiate permessage-deflate
+    ReadLimit    int64                      // DefaultReadLimit if 0
+}
+
+// Conn is a WebSocket connection on the server side
+type Conn struct {
+    conn        net.Conn
+    reader      *bufio.Reader
+    writer      *bufio.Writer
+    writeMutex  sync.Mutex
+    subprotocol string
+    compression bool
+    readLimit   int64
+    closed      bool
+    closeOnce   sync.Once
+    pongHandler func(data []byte)
+}
+
+var (
+    conns      = make(map[*Conn]struct{})
+    connsMutex sync.Mutex
+)
+
+// Upgrade the request to a WebSocket connection,
+// a HandshakeError is returned without writing the response if the handshake is invalid
+func Upgrade(w http.ResponseWriter, r *http.Request, opts *Options) (*Conn, error) {
+    if opts == nil {
+        opts = &Options{}
+    }
+
+    if r.Method != http.MethodGet {
+        return nil, &HandshakeError{Status: http.StatusMethodNotAllowed, Message: "request method is not GET"}
+    }
+    if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+        return nil, &HandshakeError{Status: http.StatusBadRequest, Message: "not a websocket handshake"}
+    }
+    if r.Header.Get("Sec-WebSocket-Version") != "13" {
+        w.Header().Set("Sec-WebSocket-Version", "13")
+
+        return nil, &HandshakeError{Status: http.StatusUpgradeRequired, Message: "unsupported version"}
+    }
+    key := r.Header.Get("Sec-WebSocket-Key")
+    if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+        return nil, &HandshakeError{Status: http.StatusBadRequest, Message: "invalid Sec-WebSocket-Key"}
+    }
+
+    checkOrigin := opts.CheckOrigin
+    if checkOrigin == nil {
+        checkOrigin = sameOrigin
+    }
+    if !checkOrigin(r) {
+        return nil, &HandshakeError{Status: http.StatusForbidden, Message: "origin not allowed"}
+    }
+
+    hijacker, ok := w.(http.Hijacker)
+    if !ok {
+        return nil, &HandshakeError{Status: http.StatusInternalServerError, Message: "response writer does not support hijacking"}
+    }
+
+    var subprotocol string
+    requested := headerTokens(r.Header, "Sec-WebSocket-Protocol")
+    for _, p := range opts.Subprotocols {
+        for _, q := range requested {
+            if p == q && subprotocol == "" {
+                subprotocol = p
+            }
+        }
+    }
+
+    compression := false
+    if opts.Compression {
+        for _, ext := range headerTokens(r.Header, "Sec-WebSocket-Extensions") {
+            if strings.TrimSpace(strings.Split(ext, ";")[0]) == "permessage-deflate" {
+                compression = true
+                break
+            }
+        }
+    }
+
+    netConn, brw, err := hijacker.Hijack()
+    if err != nil {
+        return nil, err
+    }
+
+    h := sha1.New()
+    h.Write([]byte(key + acceptGuid))
+    accept := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+    var buf strings.Builder
+    buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+    buf.WriteString("Sec-WebSocket-Accept: " + accept + "\r\n")
+    if subprotocol != "" {
+        buf.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+    }
+    if compression {
+        // no context takeover keeps every message independent
+        buf.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+    }
+    buf.WriteString("\r\n")
+
+    // clear the deadlines set by the http server
+    _ = netConn.SetDeadline(time.Time{})
+    if _, err = brw.WriteString(buf.String()); err == nil {
+        err = brw.Flush()
+    }
+    if err != nil {
+        _ = netConn.Close()
+
+        return nil, err
+    }
+
+    readLimit := opts.ReadLimit
+    if readLimit <= 0 {
+        readLimit = DefaultReadLimit
+    }
+
+    c := &Conn{
+        conn:        netConn,
+        reader:      brw.Reader,
+        writer:      bufio.NewWriter(netConn),
+        subprotocol: subprotocol,
+        compression: compression,
+        readLimit:   readLimit,
+    }
+
+    connsMutex.Lock()
+    conns[c] = struct{}{}
+    connsMutex.Unlock()
+
+    return c, nil
+}
+
+// Shutdown closes all connections with the going away code,
+// e.g. server.NewManager().OnShutdown(websocket.Shutdown)
+func Shutdown(ctx context.Context) {
+    connsMutex.Lock()
+    list := make([]*Conn, 0, len(conns))
+    for c := range conns {
+        list = append(list, c)
+    }
+    connsMutex.Unlock()
+
+    for _, c := range list {
+        select {
+        case <-ctx.Done():
+            _ = c.conn.Close()
+        default:
+            _ = c.Close(CloseGoingAway, "server shutdown")
+        }
+    }
+}
+
+// Subprotocol returns the negotiated subprotocol
+func (c *Conn) Subprotocol() string {
+    return c.subprotocol
+}
+
+// RemoteAddr
+func (c *Conn) RemoteAddr() net.Addr {
+    return c.conn.RemoteAddr()
+}
+
+// SetReadDeadline
+func (c *Conn) SetReadDeadline(t time.Time) error {
+    return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+    return c.conn.SetWriteDeadline(t)
+}
+
+// SetPongHandler is called with the payload of the received pong frames
+func (c *Conn) SetPongHandler(handler func(data []byte)) {
+    c.pongHandler = handler
+}
+
+// ReadMessage returns the next text or binary message,
+// fragmented messages are reassembled, pings are answered automatically,
+// a CloseError is returned after the close handshake
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+    var (
+        message    bytes.Buffer
+        compressed bool
+    )
+
+    for {
+        fin, rsv1, opcode, payload, err := c.readFrame(c.readLimit - int64(message.Len()))
+        if err != nil {
+            return 0, nil, c.fail(err)
+        }
+
+        switch opcode {
+        case CloseMessage:
+            code, text := CloseNoStatus, ""
+            if len(payload) >= 2 {
+                code = int(binary.BigEndian.Uint16(payload))
+                text = string(payload[2:])
+                if !validCloseCode(code) || !utf8.ValidString(text) {
+                    return 0, nil, c.fail(ErrControlFrame)
+                }
+            } else if len(payload) == 1 {
+                return 0, nil, c.fail(ErrControlFrame)
+            }
+
+            // echo the close code
+            reply := CloseNormal
+            if code != CloseNoStatus {
+                reply = code
+            }
+            _ = c.Close(reply, "")
+
+            return 0, nil, &CloseError{Code: code, Text: text}
+        case PingMessage:
+            if err = c.WriteControl(PongMessage, payload); err != nil {
+                return 0, nil, err
+            }
+
+            continue
+        case PongMessage:
+            if c.pongHandler != nil {
+                c.pongHandler(payload)
+            }
+
+            continue
+        case TextMessage, BinaryMessage:
+            if messageType != 0 {
+                return 0, nil, c.fail(errors.New("websocket: new message before the fragmented message ends"))
+            }
+            if rsv1 && !c.compression {
+                return 0, nil, c.fail(errors.New("websocket: unexpected rsv1 bit"))
+            }
+            messageType = opcode
+            compressed = rsv1
+            message.Write(payload)
+        case ContinuationMessage:
+            if messageType == 0 {
+                return 0, nil, c.fail(errors.New("websocket: continuation frame without message"))
+            }
+            if rsv1 {
+                return 0, nil, c.fail(errors.New("websocket: unexpected rsv1 bit"))
+            }
+            message.Write(payload)
+        default:
+            return 0, nil, c.fail(errors.New("websocket: unknown opcode " + strconv.Itoa(opcode)))
+        }
+
+        if !fin {
+            continue
+        }
+
+        data = message.Bytes()
+        if compressed {
+            if data, err = c.inflate(data); err != nil {
+                return 0, nil, c.fail(err)
+            }
+        }
+        if messageType == TextMessage && !utf8.Valid(data) {
+            _ = c.Close(CloseInvalidPayload, "invalid utf-8")
+
+            return 0, nil, errors.New("websocket: invalid utf-8 in text message")
+        }
+
+        return messageType, data, nil
+    }
+}
+
+// readFrame reads a frame whose payload is at most limit bytes for data frames
+func (c *Conn) readFrame(limit int64) (fin, rsv1 bool, opcode int, payload []byte, err error) {
+    var head [2]byte
+    if _, err = io.ReadFull(c.reader, head[:]); err != nil {
+        return
+    }
+
+    fin = head[0]&0x80 != 0
+    rsv1 = head[0]&0x40 != 0
+    opcode = int(head[0] & 0x0f)
+    if head[0]&0x30 != 0 {
+        err = errors.New("websocket: unexpected rsv2 or rsv3 bit")
+        return
+    }
+    // frames from the client must be masked
+    if head[1]&0x80 == 0 {
+        err = errors.New("websocket: unmasked client frame")
+        return
+    }
+
+    length := int64(head[1] & 0x7f)
+    switch length {
+    case 126:
+        var ext [2]byte
+        if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+            return
+        }
+        length = int64(binary.BigEndian.Uint16(ext[:]))
+    case 127:
+        var ext [8]byte
+        if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+            return
+        }
+        n := binary.BigEndian.Uint64(ext[:])
+        if n>>63 != 0 {
+            err = errors.New("websocket: invalid payload length")
+            return
+        }
+        length = int64(n)
+    }
+
+    if opcode >= CloseMessage {
+        if !fin || rsv1 || length > 125 {
+            err = ErrControlFrame
+            return
+        }
+    } else if length > limit {
+        err = ErrReadLimit
+        return
+    }
+
+    var mask [4]byte
+    if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+        return
+    }
+
+    payload = make([]byte, length)
+    if _, err = io.ReadFull(c.reader, payload); err != nil {
+        return
+    }
+    for i := range payload {
+        payload[i] ^= mask[i%4]
+    }
+
+    return
+}
+
+// WriteMessage sends a text or binary message in a single frame
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+    if messageType != TextMessage && messageType != BinaryMessage {
+        return errors.New("websocket: invalid message type")
+    }
+
+    rsv1 := false
+    if c.compression && len(data) > 64 {
+        compressed, err := deflate(data)
+        if err != nil {
+            return err
+        }
+        data = compressed
+        rsv1 = true
+    }
+
+    return c.writeFrame(true, rsv1, messageType, data)
+}
+
+// WriteControl sends a close, ping or pong frame
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+    if messageType < CloseMessage || len(data) > 125 {
+        return ErrControlFrame
+    }
+
+    return c.writeFrame(true, false, messageType, data)
+}
+
+// Ping the client
+func (c *Conn) Ping(data []byte) error {
+    return c.WriteControl(PingMessage, data)
+}
+
+// Close sends a close frame with the code and closes the connection
+func (c *Conn) Close(code int, reason string) (err error) {
+    c.closeOnce.Do(func() {
+        payload := make([]byte, 2, 2+len(reason))
+        binary.BigEndian.PutUint16(payload, uint16(code))
+        payload = append(payload, reason...)
+        if len(payload) > 125 {
+            payload = payload[:125]
+        }
+
+        _ = c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+        err = c.writeFrame(true, false, CloseMessage, payload)
+
+        c.writeMutex.Lock()
+        c.closed = true
+        c.writeMutex.Unlock()
+        if e := c.conn.Close(); err == nil {
+            err = e
+        }
+
+        connsMutex.Lock()
+        delete(conns, c)
+        connsMutex.Unlock()
+    })
+
+    return
+}
+
+// fail closes the connection with the code matching the error
+func (c *Conn) fail(err error) error {
+    switch err {
+    case io.EOF, io.ErrUnexpectedEOF:
+        c.closeOnce.Do(func() {
+            _ = c.conn.Close()
+            connsMutex.Lock()
+            delete(conns, c)
+            connsMutex.Unlock()
+        })
+
+        return &CloseError{Code: CloseAbnormal, Text: err.Error()}
+    case ErrReadLimit:
+        _ = c.Close(CloseMessageTooBig, "")
+    default:
+        _ = c.Close(CloseProtocolError, "")
+    }
+
+    return err
+}
+
+// writeFrame writes a frame, frames from the server are not masked
+func (c *Conn) writeFrame(fin, rsv1 bool, opcode int, payload []byte) error {
+    c.writeMutex.Lock()
+    defer c.writeMutex.Unlock()
+
+    if c.closed {
+        return ErrClosed
+    }
+
+    var head [10]byte
+    head[0] = byte(opcode)
+    if fin {
+        head[0] |= 0x80
+    }
+    if rsv1 {
+        head[0] |= 0x40
+    }
+
+    n := 2
+    length := len(payload)
+    switch {
+    case length <= 125:
+        head[1] = byte(length)
+    case length <= 0xffff:
+        head[1] = 126
+        binary.BigEndian.PutUint16(head[2:], uint16(length))
+        n += 2
+    default:
+        head[1] = 127
+        binary.BigEndian.PutUint64(head[2:], uint64(length))
+        n += 8
+    }
+
+    if _, err := c.writer.Write(head[:n]); err != nil {
+        return err
+    }
+    if _, err := c.writer.Write(payload); err != nil {
+        return err
+    }
+
+    return c.writer.Flush()
+}
+
+// inflate the payload of a compressed message
+func (c *Conn) inflate(data []byte) ([]byte, error) {
+    r := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateTail), bytes.NewReader([]byte{0x01, 0x00, 0x00, 0xff, 0xff})))
+    defer r.Close()
+
+    b, err := ioutil.ReadAll(io.LimitReader(r, c.readLimit+1))
+    if err != nil {
+        return nil, err
+    }
+    if int64(len(b)) > c.readLimit {
+        return nil, ErrReadLimit
+    }
+
+    return b, nil
+}
+
+// deflate the payload of a message
+func deflate(data []byte) ([]byte, error) {
+    var buf bytes.Buffer
+    w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+    if err != nil {
+        return nil, err
+    }
+    if _, err = w.Write(data); err != nil {
+        return nil, err
+    }
+    if err = w.Flush(); err != nil {
+        return nil, err
+    }
+
+    return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+// validCloseCode reports whether the code can be sent in a close frame
+func validCloseCode(code int) bool {
+    switch {
+    case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+        return true
+    case code >= 3000 && code <= 4999:
+        return true
+    }
+
+    return false
+}
+
+// sameOrigin reports whether the origin is absent or matches the host
+func sameOrigin(r *http.Request) bool {
+    origin := r.Header.Get("Origin")
+    if origin == "" {
+        return true
+    }
+
+    i := strings.Index(origin, "://")
+    if i < 0 {
+        return false
+    }
+
+    return strings.EqualFold(origin[i+3:], r.Host)
+}
+
+// headerTokens returns the comma separated values of the header
+func headerTokens(header http.Header, key string) (tokens []string) {
+    for _, value := range header[http.CanonicalHeaderKey(key)] {
+        for _, token := range strings.Split(value, ",") {
+            if token = strings.TrimSpace(token); token != "" {
+                tokens = append(tokens, token)
+            }
+        }
+    }
+
+    return
+}
+
+// headerContains reports whether the header contains the token case-insensitively
+func headerContains(header http.Header, key, token string) bool {
+    for _, t := range headerTokens(header, key) {
+        if strings.EqualFold(t, token) {
+            return true
+        }
+    }
+
+    return false
+}
@@ -0,0 +1,155 @@
+package websocket
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/binary"
+    "io"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+// dial performs the handshake and returns the raw connection
+func dial(t *testing.T, url, extensions string) (net.Conn, *bufio.Reader, *http.Response) {
+    conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    req := "GET / HTTP/1.1\r\nHost: " + strings.TrimPrefix(url, "http://") + "\r\n" +
+        "Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n" +
+        "Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Protocol: chat, echo\r\n"
+    if extensions != "" {
+        req += "Sec-WebSocket-Extensions: " + extensions + "\r\n"
+    }
+    if _, err = conn.Write([]byte(req + "\r\n")); err != nil {
+        t.Fatal(err)
+    }
+
+    br := bufio.NewReader(conn)
+    resp, err := http.ReadResponse(br, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    return conn, br, resp
+}
+
+// writeFrame writes a masked client frame
+func writeFrame(t *testing.T, conn net.Conn, b0 byte, payload []byte) {
+    mask := []byte{1, 2, 3, 4}
+    frame := []byte{b0, 0x80 | byte(len(payload))}
+    frame = append(frame, mask...)
+    for i, b := range payload {
+        frame = append(frame, b^mask[i%4])
+    }
+    if _, err := conn.Write(frame); err != nil {
+        t.Fatal(err)
+    }
+}
+
+// readFrame reads an unmasked server frame
+func readFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+    var head [2]byte
+    if _, err := io.ReadFull(br, head[:]); err != nil {
+        t.Fatal(err)
+    }
+    length := int(head[1] & 0x7f)
+    if length == 126 {
+        var ext [2]byte
+        _, _ = io.ReadFull(br, ext[:])
+        length = int(binary.BigEndian.Uint16(ext[:]))
+    }
+    payload := make([]byte, length)
+    if _, err := io.ReadFull(br, payload); err != nil {
+        t.Fatal(err)
+    }
+
+    return head[0], payload
+}
+
+func TestWebSocket(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        conn, err := Upgrade(w, r, &Options{Subprotocols: []string{"echo"}, Compression: true})
+        if err != nil {
+            http.Error(w, err.Error(), err.(*HandshakeError).Status)
+            return
+        }
+        for {
+            messageType, data, err := conn.ReadMessage()
+            if err != nil {
+                return
+            }
+            _ = conn.WriteMessage(messageType, data)
+        }
+    }))
+    defer server.Close()
+
+    conn, br, resp := dial(t, server.URL, "permessage-deflate; client_max_window_bits")
+    defer conn.Close()
+    if resp.StatusCode != http.StatusSwitchingProtocols {
+        t.Fatalf("status = %v, want %v", resp.StatusCode, http.StatusSwitchingProtocols)
+    }
+    if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+        t.Fatalf("Sec-WebSocket-Accept = %v", accept)
+    }
+    if protocol := resp.Header.Get("Sec-WebSocket-Protocol"); protocol != "echo" {
+        t.Fatalf("Sec-WebSocket-Protocol = %v", protocol)
+    }
+
+    // fragmented text message with a ping in between
+    writeFrame(t, conn, TextMessage, []byte("hello "))
+    writeFrame(t, conn, 0x80|PingMessage, []byte("ping"))
+    writeFrame(t, conn, 0x80|ContinuationMessage, []byte("world"))
+    if b0, payload := readFrame(t, br); b0 != 0x80|PongMessage || string(payload) != "ping" {
+        t.Fatalf("pong = %x %s", b0, payload)
+    }
+    if b0, payload := readFrame(t, br); b0 != 0x80|TextMessage || string(payload) != "hello world" {
+        t.Fatalf("echo = %x %s", b0, payload)
+    }
+
+    // compressed message
+    long := bytes.Repeat([]byte("journey "), 32)
+    compressed, _ := deflate(long)
+    writeFrame(t, conn, 0x80|0x40|BinaryMessage, compressed)
+    b0, payload := readFrame(t, br)
+    if b0 != 0x80|0x40|BinaryMessage {
+        t.Fatalf("compressed echo = %x", b0)
+    }
+    c := &Conn{readLimit: DefaultReadLimit}
+    if data, err := c.inflate(payload); err != nil || !bytes.Equal(data, long) {
+        t.Fatalf("inflate() = %s, %v", data, err)
+    }
+
+    // close handshake
+    writeFrame(t, conn, 0x80|CloseMessage, []byte{0x03, 0xe8})
+    if b0, payload := readFrame(t, br); b0 != 0x80|CloseMessage || binary.BigEndian.Uint16(payload) != CloseNormal {
+        t.Fatalf("close = %x %v", b0, payload)
+    }
+}
+
+func TestShutdown(t *testing.T) {
+    upgraded := make(chan struct{})
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        conn, err := Upgrade(w, r, nil)
+        if err != nil {
+            return
+        }
+        close(upgraded)
+        _, _, _ = conn.ReadMessage()
+    }))
+    defer server.Close()
+
+    conn, br, _ := dial(t, server.URL, "")
+    defer conn.Close()
+    <-upgraded
+
+    Shutdown(context.Background())
+    if b0, payload := readFrame(t, br); b0 != 0x80|CloseMessage || binary.BigEndian.Uint16(payload) != CloseGoingAway {
+        t.Fatalf("close = %x %v", b0, payload)
+    }
+}